@@ -0,0 +1,107 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	adminMW "bestdoctors_service/admin/middleware"
+	"bestdoctors_service/admin/validators"
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/models"
+)
+
+func SendPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	if !adminMW.RequireSuperAdmin(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		getSendPolicy(w, r)
+	case http.MethodPut:
+		updateSendPolicy(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /admin/sendpolicy - Business hours and proactive message limit
+func getSendPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy models.SendPolicy
+	if err := db.DB.First(&policy, 1).Error; err != nil {
+		http.Error(w, "Send policy not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"policy":  policy,
+	})
+}
+
+// PUT /admin/sendpolicy - Update send policy
+func updateSendPolicy(w http.ResponseWriter, r *http.Request) {
+	var req validators.UpdateSendPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var policy models.SendPolicy
+	if err := db.DB.First(&policy, 1).Error; err != nil {
+		http.Error(w, "Send policy not found", http.StatusNotFound)
+		return
+	}
+
+	if req.Timezone != nil {
+		policy.Timezone = *req.Timezone
+	}
+	if req.BusinessStart != nil {
+		policy.BusinessStart = *req.BusinessStart
+	}
+	if req.BusinessEnd != nil {
+		policy.BusinessEnd = *req.BusinessEnd
+	}
+	if req.BusinessDays != nil {
+		policy.BusinessDays = *req.BusinessDays
+	}
+	if req.MaxProactive != nil {
+		policy.MaxProactive = *req.MaxProactive
+	}
+	if req.ProactiveWindowHours != nil {
+		policy.ProactiveWindowHours = *req.ProactiveWindowHours
+	}
+
+	if policy.BusinessStart >= policy.BusinessEnd {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "business_start must be before business_end",
+		})
+		return
+	}
+
+	if err := db.DB.Save(&policy).Error; err != nil {
+		http.Error(w, "Failed to update send policy", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Send policy updated successfully",
+		"policy":  policy,
+	})
+}
@@ -0,0 +1,219 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	adminMW "bestdoctors_service/admin/middleware"
+	"bestdoctors_service/admin/validators"
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/models"
+)
+
+func TemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	if !adminMW.RequireSuperAdmin(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		listTemplates(w, r)
+	case http.MethodPost:
+		createTemplate(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func TemplateHandler(w http.ResponseWriter, r *http.Request) {
+	if !adminMW.RequireSuperAdmin(w, r) {
+		return
+	}
+
+	idStr := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/templates/"), "/")
+	if idStr == "" {
+		http.Error(w, "Template ID required", http.StatusBadRequest)
+		return
+	}
+
+	templateID, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid template ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		getTemplate(w, r, templateID)
+	case http.MethodPut:
+		updateTemplate(w, r, templateID)
+	case http.MethodDelete:
+		deleteTemplate(w, r, templateID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /admin/templates - List all templates
+func listTemplates(w http.ResponseWriter, r *http.Request) {
+	var templates []models.MessageTemplate
+	if err := db.DB.Order("name, language").Find(&templates).Error; err != nil {
+		http.Error(w, "Failed to fetch templates", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"templates": templates,
+	})
+}
+
+// POST /admin/templates - Create template
+func createTemplate(w http.ResponseWriter, r *http.Request) {
+	var req validators.CreateTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	tpl := models.MessageTemplate{
+		Name:               req.Name,
+		Language:           req.Language,
+		Body:               req.Body,
+		ProviderContentSID: req.ProviderContentSID,
+		Category:           req.Category,
+		IsActive:           true,
+	}
+	if tpl.Language == "" {
+		tpl.Language = "pt_BR"
+	}
+	if tpl.Category == "" {
+		tpl.Category = "MARKETING"
+	}
+	if req.IsActive != nil {
+		tpl.IsActive = *req.IsActive
+	}
+
+	var existing models.MessageTemplate
+	if err := db.DB.Where("name = ? AND language = ?", tpl.Name, tpl.Language).First(&existing).Error; err == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Template already exists for this language",
+		})
+		return
+	}
+
+	if err := db.DB.Create(&tpl).Error; err != nil {
+		http.Error(w, "Failed to create template", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"message":  "Template created successfully",
+		"template": tpl,
+	})
+}
+
+// GET /admin/templates/:id - Get template details
+func getTemplate(w http.ResponseWriter, r *http.Request, templateID int) {
+	var tpl models.MessageTemplate
+	if err := db.DB.First(&tpl, templateID).Error; err != nil {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"template": tpl,
+	})
+}
+
+// PUT /admin/templates/:id - Update template
+func updateTemplate(w http.ResponseWriter, r *http.Request, templateID int) {
+	var req validators.UpdateTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var tpl models.MessageTemplate
+	if err := db.DB.First(&tpl, templateID).Error; err != nil {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	if req.Body != nil {
+		tpl.Body = *req.Body
+	}
+	if req.ProviderContentSID != nil {
+		tpl.ProviderContentSID = *req.ProviderContentSID
+	}
+	if req.Category != nil {
+		tpl.Category = *req.Category
+	}
+	if req.IsActive != nil {
+		tpl.IsActive = *req.IsActive
+	}
+
+	if err := db.DB.Save(&tpl).Error; err != nil {
+		http.Error(w, "Failed to update template", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"message":  "Template updated successfully",
+		"template": tpl,
+	})
+}
+
+// DELETE /admin/templates/:id - Deactivate template (queued sends still reference it)
+func deleteTemplate(w http.ResponseWriter, r *http.Request, templateID int) {
+	var tpl models.MessageTemplate
+	if err := db.DB.First(&tpl, templateID).Error; err != nil {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	tpl.IsActive = false
+	if err := db.DB.Save(&tpl).Error; err != nil {
+		http.Error(w, "Failed to delete template", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Template deleted successfully",
+	})
+}
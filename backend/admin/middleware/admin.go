@@ -3,9 +3,8 @@ package middleware
 import (
 	"context"
 	"net/http"
-	"os"
-	"strings"
 
+	"bestdoctors_service/internal/config"
 	"bestdoctors_service/internal/session"
 )
 
@@ -63,16 +62,9 @@ func RequireSuperAdmin(w http.ResponseWriter, r *http.Request) bool {
 }
 
 func GetSuperAdminCredentials() (username, password string) {
-	cleanEnv := func(key string) string {
-		v := os.Getenv(key)
-		v = strings.TrimSpace(v)
-		v = strings.ReplaceAll(v, "\r", "")
-		v = strings.ReplaceAll(v, "\n", "")
-		return v
-	}
 	
-	username = cleanEnv("SUPERADMIN_USERNAME")
-	password = cleanEnv("SUPERADMIN_PASSWORD")
+	username = config.Env("SUPERADMIN_USERNAME")
+	password = config.Env("SUPERADMIN_PASSWORD")
 	
 	return username, password
 }
@@ -0,0 +1,49 @@
+package validators
+
+import (
+	"errors"
+	"regexp"
+	"time"
+)
+
+var (
+	clockRegex        = regexp.MustCompile(`^(([01]\d|2[0-3]):[0-5]\d|24:00)$`)
+	businessDaysRegex = regexp.MustCompile(`^[0-6](,[0-6])*$`)
+)
+
+type UpdateSendPolicyRequest struct {
+	Timezone             *string `json:"timezone"`
+	BusinessStart        *string `json:"business_start"`
+	BusinessEnd          *string `json:"business_end"`
+	BusinessDays         *string `json:"business_days"`
+	MaxProactive         *int    `json:"max_proactive"`
+	ProactiveWindowHours *int    `json:"proactive_window_hours"`
+}
+
+func (r *UpdateSendPolicyRequest) Validate() error {
+	if r.Timezone != nil {
+		if _, err := time.LoadLocation(*r.Timezone); err != nil || *r.Timezone == "" {
+			return errors.New("timezone must be an IANA name such as America/Sao_Paulo")
+		}
+	}
+
+	if r.BusinessStart != nil && !clockRegex.MatchString(*r.BusinessStart) {
+		return errors.New("business_start must be HH:MM")
+	}
+	if r.BusinessEnd != nil && !clockRegex.MatchString(*r.BusinessEnd) {
+		return errors.New("business_end must be HH:MM")
+	}
+
+	if r.BusinessDays != nil && !businessDaysRegex.MatchString(*r.BusinessDays) {
+		return errors.New("business_days must be weekday numbers separated by commas (0 = Sunday)")
+	}
+
+	if r.MaxProactive != nil && (*r.MaxProactive < 0 || *r.MaxProactive > 100) {
+		return errors.New("max_proactive must be between 0 and 100")
+	}
+	if r.ProactiveWindowHours != nil && (*r.ProactiveWindowHours < 1 || *r.ProactiveWindowHours > 720) {
+		return errors.New("proactive_window_hours must be between 1 and 720")
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package validators
+
+import (
+	"errors"
+	"regexp"
+)
+
+var (
+	templateNameRegex     = regexp.MustCompile(`^[a-z0-9_]+$`)
+	templateLanguageRegex = regexp.MustCompile(`^[a-z]{2}(_[A-Z]{2})?$`)
+	validCategories       = map[string]bool{
+		"MARKETING":      true,
+		"UTILITY":        true,
+		"AUTHENTICATION": true,
+	}
+)
+
+type CreateTemplateRequest struct {
+	Name               string `json:"name"`
+	Language           string `json:"language"`
+	Body               string `json:"body"`
+	ProviderContentSID string `json:"provider_content_sid"`
+	Category           string `json:"category"`
+	IsActive           *bool  `json:"is_active"`
+}
+
+type UpdateTemplateRequest struct {
+	Body               *string `json:"body"`
+	ProviderContentSID *string `json:"provider_content_sid"`
+	Category           *string `json:"category"`
+	IsActive           *bool   `json:"is_active"`
+}
+
+func (r *CreateTemplateRequest) Validate() error {
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(r.Name) > 100 || !templateNameRegex.MatchString(r.Name) {
+		return errors.New("name can only contain lowercase letters, numbers, and underscores (max 100)")
+	}
+
+	if r.Language != "" && !templateLanguageRegex.MatchString(r.Language) {
+		return errors.New("language must look like pt_BR or en")
+	}
+
+	if r.Body == "" {
+		return errors.New("body is required")
+	}
+	if len(r.Body) > 1024 {
+		return errors.New("body must be at most 1024 characters")
+	}
+
+	if r.Category != "" && !validCategories[r.Category] {
+		return errors.New("category must be MARKETING, UTILITY or AUTHENTICATION")
+	}
+
+	return nil
+}
+
+func (r *UpdateTemplateRequest) Validate() error {
+	if r.Body != nil {
+		if *r.Body == "" {
+			return errors.New("body cannot be empty")
+		}
+		if len(*r.Body) > 1024 {
+			return errors.New("body must be at most 1024 characters")
+		}
+	}
+
+	if r.Category != nil && !validCategories[*r.Category] {
+		return errors.New("category must be MARKETING, UTILITY or AUTHENTICATION")
+	}
+
+	return nil
+}
@@ -1,38 +1,30 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
-	"os"
-	"strings"
 
 	adminHandler "bestdoctors_service/admin/handlers"
 	adminMW "bestdoctors_service/admin/middleware"
+	"bestdoctors_service/internal/config"
 	"bestdoctors_service/middleware"
 	"bestdoctors_service/routes"
 
 	"golang.org/x/time/rate"
 )
 
-func getEnv(key string) string {
-	value := os.Getenv(key)
-	value = strings.TrimSpace(value)
-	value = strings.ReplaceAll(value, "\r", "")
-	value = strings.ReplaceAll(value, "\n", "")
-	return value
-}
-
-
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		origin := getEnv("ALLOWED_ORIGIN")
+		origin := config.Env("ALLOWED_ORIGIN")
 		if origin == "" {
 			origin = "http://localhost" 
 		}
 
 		w.Header().Set("Access-Control-Allow-Origin", origin)
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key, If-Match")
+		w.Header().Set("Access-Control-Expose-Headers", "Cache-Status, Last-Refreshed")
 		w.Header().Set("Access-Control-Allow-Credentials", "true") 
 		w.Header().Set("Access-Control-Max-Age", "3600")
 
@@ -52,7 +44,7 @@ func corsMiddleware(next http.Handler) http.Handler {
 
 func main() {
 
-	redisURL := getEnv("REDIS_URL")
+	redisURL := config.Env("REDIS_URL")
 	if redisURL == "" {
 		log.Fatal("REDIS_URL environment variable is required")
 	}
@@ -61,6 +53,25 @@ func main() {
 		log.Fatalf("Failed to initialize session store: %v", err)
 	}
 
+	if err := routes.InitMessageProvider(); err != nil {
+		log.Printf("WhatsApp provider not configured: %v", err)
+	}
+
+	if err := routes.InitMediaStore(); err != nil {
+		log.Printf("Media storage not configured: %v", err)
+	}
+
+	go routes.StartOutboxWorker(context.Background())
+	go routes.StartCampaignWorker(context.Background())
+	go routes.StartEventListener(context.Background())
+	go routes.StartAIReactivationWorker(context.Background())
+	go routes.StartPipelineWorker(context.Background())
+	go routes.StartSearchIndexer(context.Background())
+	go routes.StartFlowStateWorker(context.Background())
+	go routes.StartContactLinker(context.Background())
+	go routes.StartMetricFactsWorker(context.Background())
+	go routes.StartConsentKeywordScanner(context.Background())
+
 	loginLimiter := middleware.NewIPRateLimiter(rate.Limit(5.0/60.0), 5)
 	apiLimiter := middleware.NewIPRateLimiter(rate.Limit(100.0/60.0), 100)
 
@@ -80,6 +91,13 @@ func main() {
 	mux.Handle("/auth/login", middleware.RateLimitMiddleware(loginLimiter)(http.HandlerFunc(routes.LoginHandler)))
 	mux.HandleFunc("/auth/logout", routes.LogoutHandler)
 
+	mux.HandleFunc("/webhooks/whatsapp", routes.WhatsAppWebhookHandler)
+	mux.HandleFunc("/webhooks/whatsapp/status", routes.WhatsAppStatusHandler)
+
+	if h := routes.MediaFileHandler(); h != nil {
+		mux.Handle("/media/", h)
+	}
+
 	mux.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {})
 
 	mux.Handle("/auth/me", authMW(http.HandlerFunc(routes.MeHandler)))
@@ -87,14 +105,35 @@ func main() {
 	protectedMux := http.NewServeMux()
 	protectedMux.HandleFunc("/bestdoctors/sessionphone", routes.SessionPhoneHandler)
 	protectedMux.HandleFunc("/bestdoctors/sessionphone/active", routes.ToggleAIHandler)
+	protectedMux.HandleFunc("/bestdoctors/sessionphone/read", routes.SessionReadHandler)
+	protectedMux.HandleFunc("/bestdoctors/sessionevents", routes.SessionEventsHandler)
+	protectedMux.HandleFunc("/bestdoctors/tags", routes.TagsHandler)
+	protectedMux.HandleFunc("/bestdoctors/tags/", routes.TagHandler)
+	protectedMux.HandleFunc("/bestdoctors/sessiontags", routes.SessionTagsHandler)
+	protectedMux.HandleFunc("/bestdoctors/notes", routes.NotesHandler)
+	protectedMux.HandleFunc("/bestdoctors/notes/", routes.NoteHandler)
 	protectedMux.HandleFunc("/bestdoctors/chathistory", routes.ChatHistoryHandler)
 	protectedMux.HandleFunc("/bestdoctors/sessiondelta", routes.SessionDeltaHandler)
 	protectedMux.HandleFunc("/bestdoctors/metrics/session", routes.SessionMetricsHandler)
 	protectedMux.HandleFunc("/bestdoctors/metrics/abandonment", routes.AbandonmentRateHandler)
 	protectedMux.HandleFunc("/bestdoctors/metrics/flowdepth", routes.FlowDepthHandler)
 	protectedMux.HandleFunc("/bestdoctors/metrics/reengagement", routes.ReengagementRateHandler)
+	protectedMux.HandleFunc("/bestdoctors/metrics/cache", routes.MetricsCacheHandler)
 	protectedMux.HandleFunc("/bestdoctors/sendmessage", routes.SendMessageHandler)
+	protectedMux.HandleFunc("/bestdoctors/scheduledmessages", routes.ScheduledMessagesHandler)
 	protectedMux.HandleFunc("/bestdoctors/report", routes.ReportHandler)
+	protectedMux.HandleFunc("/bestdoctors/templates", routes.TemplatesHandler)
+	protectedMux.HandleFunc("/bestdoctors/campaigns", routes.CampaignsHandler)
+	protectedMux.HandleFunc("/bestdoctors/campaigns/", routes.CampaignHandler)
+	protectedMux.HandleFunc("/bestdoctors/consent", routes.ConsentHandler)
+	protectedMux.HandleFunc("/bestdoctors/events", routes.EventsHandler)
+	protectedMux.HandleFunc("/bestdoctors/handoffs", routes.HandoffsHandler)
+	protectedMux.HandleFunc("/bestdoctors/handoffs/", routes.HandoffHandler)
+	protectedMux.HandleFunc("/bestdoctors/pipeline", routes.PipelineHandler)
+	protectedMux.HandleFunc("/bestdoctors/pipeline/move", routes.PipelineMoveHandler)
+	protectedMux.HandleFunc("/bestdoctors/search", routes.SearchHandler)
+	protectedMux.HandleFunc("/bestdoctors/contacts", routes.ContactsHandler)
+	protectedMux.HandleFunc("/bestdoctors/contacts/", routes.ContactHandler)
 
 	mux.Handle("/bestdoctors/", middleware.RateLimitMiddleware(apiLimiter)(authMW(protectedMux)))
 
@@ -108,12 +147,18 @@ func main() {
 	adminMux := http.NewServeMux()
 	adminMux.HandleFunc("/admin/users", adminHandler.UsersHandler)     
 	adminMux.HandleFunc("/admin/users/", adminHandler.UserHandler)     
+	adminMux.HandleFunc("/admin/templates", adminHandler.TemplatesHandler)
+	adminMux.HandleFunc("/admin/templates/", adminHandler.TemplateHandler)
+	adminMux.HandleFunc("/admin/sendpolicy", adminHandler.SendPolicyHandler)
 	
 	adminAuthMW := adminMW.AdminMiddleware(routes.GetSessionStore())
 	mux.Handle("/admin/users", adminAuthMW(adminMux))
 	mux.Handle("/admin/users/", adminAuthMW(adminMux))
+	mux.Handle("/admin/templates", adminAuthMW(adminMux))
+	mux.Handle("/admin/templates/", adminAuthMW(adminMux))
+	mux.Handle("/admin/sendpolicy", adminAuthMW(adminMux))
 
-	port := getEnv("PORT")
+	port := config.Env("PORT")
 	if port == "" {
 		port = "9002"
 	}
@@ -0,0 +1,235 @@
+// Command normalizephones rewrites the phone numbers already stored in the
+// database to E.164 (see internal/phonenum) and re-keys contacts and
+// consents, merging rows that turn out to be the same number. Numbers it
+// cannot normalize are left untouched and listed at the end.
+//
+// Usage:
+//
+//	go run ./cmd/normalizephones [-dry-run]
+//
+// It uses the same PG_* environment as the backend and is safe to run again.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/internal/phonenum"
+	"bestdoctors_service/models"
+
+	"gorm.io/gorm"
+)
+
+var errDryRun = errors.New("dry run")
+
+type failure struct {
+	Table string
+	Value string
+	Rows  int64
+	Err   error
+}
+
+type backfill struct {
+	tx       *gorm.DB
+	updated  map[string]int64
+	merged   map[string]int64
+	failures []failure
+}
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report what would change and roll back")
+	flag.Parse()
+
+	b := &backfill{updated: map[string]int64{}, merged: map[string]int64{}}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		b.tx = tx
+		for _, c := range []struct{ table, column string }{
+			{"session_phones", "phone"},
+			{"outbound_messages", "recipient"},
+			{"campaign_recipients", "phone"},
+		} {
+			if err := b.normalizeColumn(c.table, c.column); err != nil {
+				return fmt.Errorf("%s.%s: %w", c.table, c.column, err)
+			}
+		}
+		if err := b.rekeyConsents(); err != nil {
+			return fmt.Errorf("contact_consents: %w", err)
+		}
+		if err := b.rekeyContacts(); err != nil {
+			return fmt.Errorf("contacts: %w", err)
+		}
+		if *dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		log.Fatalf("backfill failed, nothing was changed: %v", err)
+	}
+
+	b.print(*dryRun)
+	if len(b.failures) > 0 {
+		os.Exit(2)
+	}
+}
+
+// normalizeColumn rewrites every distinct value of table.column to E.164.
+func (b *backfill) normalizeColumn(table, column string) error {
+	var values []struct {
+		Value string
+		Rows  int64
+	}
+	if err := b.tx.Table(table).
+		Select(column + " AS value, COUNT(*) AS rows").
+		Where(column + " IS NOT NULL AND " + column + " <> ''").
+		Group(column).
+		Scan(&values).Error; err != nil {
+		return err
+	}
+
+	for _, v := range values {
+		n, err := phonenum.Normalize(v.Value)
+		if err != nil {
+			b.failures = append(b.failures, failure{table, v.Value, v.Rows, err})
+			continue
+		}
+		if n == v.Value {
+			continue
+		}
+		res := b.tx.Table(table).Where(column+" = ?", v.Value).UpdateColumn(column, n)
+		if res.Error != nil {
+			return res.Error
+		}
+		b.updated[table] += res.RowsAffected
+	}
+	return nil
+}
+
+// rekeyConsents moves contact_consents and consent_events to the
+// normalized digits. When two rows collapse into one, the most recently
+// updated status wins.
+func (b *backfill) rekeyConsents() error {
+	var consents []models.ContactConsent
+	if err := b.tx.Order("updated_at desc").Find(&consents).Error; err != nil {
+		return err
+	}
+	for _, c := range consents {
+		if _, err := phonenum.Normalize(c.Phone); err != nil {
+			b.failures = append(b.failures, failure{"contact_consents", c.Phone, 1, err})
+			continue
+		}
+		key := phonenum.Key(c.Phone)
+		if key == c.Phone {
+			continue
+		}
+
+		var existing models.ContactConsent
+		err := b.tx.Where("phone = ?", key).First(&existing).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := b.tx.Model(&models.ContactConsent{}).Where("phone = ?", c.Phone).UpdateColumn("phone", key).Error; err != nil {
+				return err
+			}
+			b.updated["contact_consents"]++
+		case err != nil:
+			return err
+		default:
+			// Rows are visited newest first, so existing is either newer or
+			// was itself re-keyed from a newer row.
+			if err := b.tx.Where("phone = ?", c.Phone).Delete(&models.ContactConsent{}).Error; err != nil {
+				return err
+			}
+			b.merged["contact_consents"]++
+		}
+
+		res := b.tx.Model(&models.ConsentEvent{}).Where("phone = ?", c.Phone).UpdateColumn("phone", key)
+		if res.Error != nil {
+			return res.Error
+		}
+		b.updated["consent_events"] += res.RowsAffected
+	}
+	return nil
+}
+
+// rekeyContacts moves contacts to the normalized digits. Contacts that end
+// up with the same number are merged into the oldest one: its sessions are
+// moved over and empty profile fields filled in.
+func (b *backfill) rekeyContacts() error {
+	var contacts []models.Contact
+	if err := b.tx.Order("id").Find(&contacts).Error; err != nil {
+		return err
+	}
+	for _, c := range contacts {
+		if _, err := phonenum.Normalize(c.Phone); err != nil {
+			b.failures = append(b.failures, failure{"contacts", c.Phone, 1, err})
+			continue
+		}
+		key := phonenum.Key(c.Phone)
+		if key == c.Phone {
+			continue
+		}
+
+		var target models.Contact
+		err := b.tx.Where("phone = ?", key).First(&target).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := b.tx.Model(&c).UpdateColumn("phone", key).Error; err != nil {
+				return err
+			}
+			b.updated["contacts"]++
+		case err != nil:
+			return err
+		default:
+			if err := b.mergeContact(&target, &c); err != nil {
+				return err
+			}
+			b.merged["contacts"]++
+		}
+	}
+	return nil
+}
+
+func (b *backfill) mergeContact(into, from *models.Contact) error {
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&into.Name, from.Name},
+		{&into.Email, from.Email},
+		{&into.CPF, from.CPF},
+		{&into.Convenio, from.Convenio},
+		{&into.PreferredSpecialty, from.PreferredSpecialty},
+	} {
+		if *f.dst == "" {
+			*f.dst = f.src
+		}
+	}
+	if err := b.tx.Save(into).Error; err != nil {
+		return err
+	}
+	if err := b.tx.Model(&models.SessionPhone{}).Where("contact_id = ?", from.ID).UpdateColumn("contact_id", into.ID).Error; err != nil {
+		return err
+	}
+	return b.tx.Delete(from).Error
+}
+
+func (b *backfill) print(dryRun bool) {
+	if dryRun {
+		fmt.Println("Dry run: no changes were saved.")
+	}
+	for _, t := range []string{"session_phones", "outbound_messages", "campaign_recipients", "contact_consents", "consent_events", "contacts"} {
+		fmt.Printf("%-20s updated %d, merged %d\n", t, b.updated[t], b.merged[t])
+	}
+	if len(b.failures) == 0 {
+		fmt.Println("All numbers normalized.")
+		return
+	}
+	fmt.Printf("\n%d value(s) could not be normalized and were left as is:\n", len(b.failures))
+	for _, f := range b.failures {
+		fmt.Printf("  %-20s %-24q rows=%d  %v\n", f.Table, f.Value, f.Rows, f.Err)
+	}
+}
@@ -0,0 +1,17 @@
+// Package config reads settings from the environment.
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// Env returns the environment variable key without surrounding spaces or
+// line breaks, which .env files edited on Windows leave behind.
+func Env(key string) string {
+	v := os.Getenv(key)
+	v = strings.TrimSpace(v)
+	v = strings.ReplaceAll(v, "\r", "")
+	v = strings.ReplaceAll(v, "\n", "")
+	return v
+}
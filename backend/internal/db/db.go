@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"log"
 	"net/url"
-	"os"
 	"strings"
+	"testing"
 	"time"
 
+	"bestdoctors_service/internal/config"
+
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -15,30 +17,35 @@ import (
 
 var (
 	SupabaseDB *gorm.DB
-	PostgresDB *gorm.DB
 	DB         *gorm.DB
+
+	dsn string
 )
 
+// DSN returns the connection string used for DB, for code that needs its own
+// dedicated connection (e.g. LISTEN).
+func DSN() string {
+	return dsn
+}
+
 func init() {
+	// Test binaries don't connect on import; tests that need a database
+	// open one themselves and point DB at it.
+	if testing.Testing() {
+		return
+	}
+
 	cfg := &gorm.Config{
 		PrepareStmt: false,
 		Logger:      logger.Default.LogMode(logger.Silent),
 	}
 
-	cleanEnv := func(key string) string {
-		v := os.Getenv(key)
-		v = strings.TrimSpace(v)
-		v = strings.ReplaceAll(v, "\r", "")
-		v = strings.ReplaceAll(v, "\n", "")
-		return v
-	}
-	
-	pgHost := cleanEnv("PG_HOST")
-	pgPort := cleanEnv("PG_PORT")
-	pgUser := cleanEnv("PG_USER")
-	pgPassword := cleanEnv("PG_PASSWORD")
-	pgDatabase := cleanEnv("PG_DATABASE")
-	pgSSLMode := cleanEnv("PG_SSLMODE")
+	pgHost := config.Env("PG_HOST")
+	pgPort := config.Env("PG_PORT")
+	pgUser := config.Env("PG_USER")
+	pgPassword := config.Env("PG_PASSWORD")
+	pgDatabase := config.Env("PG_DATABASE")
+	pgSSLMode := config.Env("PG_SSLMODE")
 	
 	if pgSSLMode == "" {
 		pgSSLMode = "disable"
@@ -60,6 +67,7 @@ func init() {
 	sqlSupa.SetConnMaxLifetime(5 * time.Minute)
 	SupabaseDB = supaConn
 	DB = supaConn
+	dsn = supaDSN
 
 }
 
@@ -0,0 +1,68 @@
+package messaging
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Consent keywords, compared after normalising the whole message (upper
+// case, no accents, no punctuation). Only exact matches count so that
+// "quero parar de fumar" is not an opt-out.
+var (
+	optOutKeywords = map[string]bool{
+		"PARAR":             true,
+		"PARE":              true,
+		"STOP":              true,
+		"SAIR":              true,
+		"DESCADASTRAR":      true,
+		"CANCELAR ENVIO":    true,
+		"NAO QUERO RECEBER": true,
+	}
+	optInKeywords = map[string]bool{
+		"VOLTAR": true,
+		"START":  true,
+		"ACEITO": true,
+	}
+)
+
+// Consent keyword results.
+const (
+	ConsentOptOut = "opt_out"
+	ConsentOptIn  = "opt_in"
+)
+
+// DetectConsentKeyword returns ConsentOptOut or ConsentOptIn when body is
+// one of the consent keywords, or "" otherwise.
+func DetectConsentKeyword(body string) string {
+	k := normalizeKeyword(body)
+	switch {
+	case optOutKeywords[k]:
+		return ConsentOptOut
+	case optInKeywords[k]:
+		return ConsentOptIn
+	}
+	return ""
+}
+
+var accentFold = strings.NewReplacer(
+	"Á", "A", "À", "A", "Â", "A", "Ã", "A", "Ä", "A",
+	"É", "E", "Ê", "E", "È", "E",
+	"Í", "I", "Î", "I",
+	"Ó", "O", "Ô", "O", "Õ", "O", "Ö", "O",
+	"Ú", "U", "Û", "U", "Ü", "U",
+	"Ç", "C",
+)
+
+func normalizeKeyword(s string) string {
+	s = accentFold.Replace(strings.ToUpper(s))
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
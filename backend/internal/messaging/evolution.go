@@ -0,0 +1,68 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+type EvolutionConfig struct {
+	BaseURL  string
+	APIKey   string
+	Instance string
+}
+
+// EvolutionProvider talks to a self-hosted Evolution API instance.
+type EvolutionProvider struct {
+	cfg EvolutionConfig
+}
+
+func NewEvolutionProvider(cfg EvolutionConfig) (*EvolutionProvider, error) {
+	if cfg.BaseURL == "" || cfg.APIKey == "" || cfg.Instance == "" {
+		return nil, errors.New("evolution configuration missing")
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	return &EvolutionProvider{cfg: cfg}, nil
+}
+
+func (p *EvolutionProvider) Name() string { return "evolution" }
+
+func (p *EvolutionProvider) Send(ctx context.Context, msg OutboundMessage) (SendResult, error) {
+	endpoint := p.cfg.BaseURL + "/message/sendText/" + p.cfg.Instance
+	payload := map[string]interface{}{
+		"number": stripWhatsAppPrefix(msg.To),
+		"text":   msg.Body,
+	}
+	if m := msg.Media; m != nil {
+		endpoint = p.cfg.BaseURL + "/message/sendMedia/" + p.cfg.Instance
+		payload = map[string]interface{}{
+			"number":    stripWhatsAppPrefix(msg.To),
+			"mediatype": m.Kind(),
+			"mimetype":  m.ContentType,
+			"media":     m.URL,
+			"caption":   msg.Body,
+			"fileName":  m.Filename,
+		}
+	}
+
+	body, err := postJSON(ctx, p.Name(), endpoint, map[string]string{"apikey": p.cfg.APIKey}, payload)
+	if err != nil {
+		return SendResult{}, err
+	}
+
+	var parsed struct {
+		Key struct {
+			ID string `json:"id"`
+		} `json:"key"`
+		Status string `json:"status"`
+	}
+	_ = json.Unmarshal(body, &parsed)
+
+	return SendResult{
+		Provider:          p.Name(),
+		ProviderMessageID: parsed.Key.ID,
+		Status:            NormalizeStatus(parsed.Status),
+		Raw:               rawOrNil(body),
+	}, nil
+}
@@ -0,0 +1,47 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// FakeProvider accepts every message and keeps it in memory. It is meant
+// for tests and local development (WHATSAPP_PROVIDER=fake).
+type FakeProvider struct {
+	mu   sync.Mutex
+	sent []OutboundMessage
+	// Err, when set, is returned by Send instead of accepting the message.
+	Err error
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{}
+}
+
+func (p *FakeProvider) Name() string { return "fake" }
+
+func (p *FakeProvider) Send(ctx context.Context, msg OutboundMessage) (SendResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.Err != nil {
+		return SendResult{}, p.Err
+	}
+	p.sent = append(p.sent, msg)
+	return SendResult{
+		Provider:          p.Name(),
+		ProviderMessageID: fmt.Sprintf("fake-%d", len(p.sent)),
+		Status:            StatusQueued,
+	}, nil
+}
+
+// Sent returns a copy of every message accepted so far.
+func (p *FakeProvider) Sent() []OutboundMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := make([]OutboundMessage, len(p.sent))
+	copy(out, p.sent)
+	return out
+}
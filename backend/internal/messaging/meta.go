@@ -0,0 +1,106 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const defaultMetaGraphURL = "https://graph.facebook.com/v19.0"
+
+type MetaConfig struct {
+	AccessToken   string
+	PhoneNumberID string
+	GraphURL      string
+}
+
+// MetaProvider talks to the WhatsApp Cloud API.
+type MetaProvider struct {
+	cfg MetaConfig
+}
+
+func NewMetaProvider(cfg MetaConfig) (*MetaProvider, error) {
+	if cfg.AccessToken == "" || cfg.PhoneNumberID == "" {
+		return nil, errors.New("meta whatsapp configuration missing")
+	}
+	if cfg.GraphURL == "" {
+		cfg.GraphURL = defaultMetaGraphURL
+	}
+	cfg.GraphURL = strings.TrimRight(cfg.GraphURL, "/")
+	return &MetaProvider{cfg: cfg}, nil
+}
+
+func (p *MetaProvider) Name() string { return "meta" }
+
+func (p *MetaProvider) Send(ctx context.Context, msg OutboundMessage) (SendResult, error) {
+	payload := map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"to":                stripWhatsAppPrefix(msg.To),
+		"type":              "text",
+		"text":              map[string]interface{}{"body": msg.Body},
+	}
+	if t := msg.Template; t != nil && t.ContentID != "" {
+		delete(payload, "text")
+		payload["type"] = "template"
+		payload["template"] = metaTemplate(t)
+	} else if m := msg.Media; m != nil {
+		kind := m.Kind()
+		media := map[string]interface{}{"link": m.URL}
+		if msg.Body != "" && kind != "audio" {
+			media["caption"] = msg.Body
+		}
+		if kind == "document" && m.Filename != "" {
+			media["filename"] = m.Filename
+		}
+		delete(payload, "text")
+		payload["type"] = kind
+		payload[kind] = media
+	}
+
+	body, err := postJSON(ctx, p.Name(), p.cfg.GraphURL+"/"+p.cfg.PhoneNumberID+"/messages",
+		map[string]string{"Authorization": "Bearer " + p.cfg.AccessToken}, payload)
+	if err != nil {
+		return SendResult{}, err
+	}
+
+	var parsed struct {
+		Messages []struct {
+			ID     string `json:"id"`
+			Status string `json:"message_status"`
+		} `json:"messages"`
+	}
+	_ = json.Unmarshal(body, &parsed)
+
+	res := SendResult{Provider: p.Name(), Status: StatusQueued, Raw: rawOrNil(body)}
+	if len(parsed.Messages) > 0 {
+		res.ProviderMessageID = parsed.Messages[0].ID
+		if parsed.Messages[0].Status != "" {
+			res.Status = NormalizeStatus(parsed.Messages[0].Status)
+		}
+	}
+	return res, nil
+}
+
+func metaTemplate(t *Template) map[string]interface{} {
+	params := make([]map[string]interface{}, 0, len(t.Params))
+	for _, p := range t.Params {
+		param := map[string]interface{}{"type": "text", "text": p.Value}
+		if _, err := strconv.Atoi(p.Name); err != nil {
+			param["parameter_name"] = p.Name
+		}
+		params = append(params, param)
+	}
+
+	tpl := map[string]interface{}{
+		"name":     t.ContentID,
+		"language": map[string]interface{}{"code": t.Language},
+	}
+	if len(params) > 0 {
+		tpl["components"] = []map[string]interface{}{
+			{"type": "body", "parameters": params},
+		}
+	}
+	return tpl
+}
@@ -0,0 +1,130 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"bestdoctors_service/internal/config"
+)
+
+// OutboundMessage is a provider-agnostic WhatsApp message. Body is always
+// the rendered text; providers with native template support use Template
+// instead when it is set. Media, when set, is sent with Body as its caption.
+type OutboundMessage struct {
+	To       string
+	Body     string
+	Template *Template
+	Media    *Media
+}
+
+// Media is an attachment reachable by the provider at URL.
+type Media struct {
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Filename    string `json:"filename,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+}
+
+// Kind is the WhatsApp media category: image, audio, video or document.
+func (m *Media) Kind() string {
+	switch {
+	case strings.HasPrefix(m.ContentType, "image/"):
+		return "image"
+	case strings.HasPrefix(m.ContentType, "audio/"):
+		return "audio"
+	case strings.HasPrefix(m.ContentType, "video/"):
+		return "video"
+	default:
+		return "document"
+	}
+}
+
+// SendResult is what a provider returns once it accepted the message.
+type SendResult struct {
+	Provider          string          `json:"provider"`
+	ProviderMessageID string          `json:"provider_message_id"`
+	Status            string          `json:"status"`
+	Raw               json.RawMessage `json:"raw,omitempty"`
+}
+
+// MessageProvider sends WhatsApp messages through a specific vendor.
+type MessageProvider interface {
+	Name() string
+	Send(ctx context.Context, msg OutboundMessage) (SendResult, error)
+}
+
+// ProviderError is returned when the vendor API answered with a non-2xx status.
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s error %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// NewProviderFromEnv builds the provider selected by WHATSAPP_PROVIDER
+// (twilio, meta, evolution, zapi or fake). Twilio is the default.
+func NewProviderFromEnv() (MessageProvider, error) {
+	name := strings.ToLower(config.Env("WHATSAPP_PROVIDER"))
+	switch name {
+	case "", "twilio":
+		return NewTwilioProvider(TwilioConfig{
+			AccountSID: config.Env("TWILIO_ACCOUNT_SID"),
+			AuthToken:  config.Env("TWILIO_AUTH_TOKEN"),
+			APIURL:     config.Env("TWILIO_URL"),
+			From:       config.Env("TWILIO_FROM"),
+
+			StatusCallbackURL: statusCallbackURL(),
+		})
+	case "meta":
+		return NewMetaProvider(MetaConfig{
+			AccessToken:   config.Env("META_WHATSAPP_TOKEN"),
+			PhoneNumberID: config.Env("META_PHONE_NUMBER_ID"),
+			GraphURL:      config.Env("META_GRAPH_URL"),
+		})
+	case "evolution":
+		return NewEvolutionProvider(EvolutionConfig{
+			BaseURL:  config.Env("EVOLUTION_URL"),
+			APIKey:   config.Env("EVOLUTION_API_KEY"),
+			Instance: config.Env("EVOLUTION_INSTANCE"),
+		})
+	case "zapi", "z-api":
+		return NewZAPIProvider(ZAPIConfig{
+			BaseURL:     config.Env("ZAPI_URL"),
+			InstanceID:  config.Env("ZAPI_INSTANCE_ID"),
+			Token:       config.Env("ZAPI_TOKEN"),
+			ClientToken: config.Env("ZAPI_CLIENT_TOKEN"),
+		})
+	case "fake":
+		return NewFakeProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown WHATSAPP_PROVIDER %q", name)
+	}
+}
+
+// statusCallbackURL is TWILIO_STATUS_CALLBACK_URL, or the panel's own
+// status endpoint when WEBHOOK_BASE_URL is known.
+func statusCallbackURL() string {
+	if v := config.Env("TWILIO_STATUS_CALLBACK_URL"); v != "" {
+		return v
+	}
+	if base := config.Env("WEBHOOK_BASE_URL"); base != "" {
+		return strings.TrimRight(base, "/") + "/webhooks/whatsapp/status"
+	}
+	return ""
+}
+
+// stripWhatsAppPrefix turns "whatsapp:+5541..." into "5541..." for vendors
+// that expect bare digits.
+func stripWhatsAppPrefix(to string) string {
+	to = strings.TrimPrefix(to, "whatsapp:")
+	return strings.TrimPrefix(to, "+")
+}
@@ -0,0 +1,346 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// capturedRequest is what a provider sent to the test server.
+type capturedRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// providerServer answers every request with status and body and records
+// the last one.
+func providerServer(t *testing.T, status int, body string) (*httptest.Server, *capturedRequest) {
+	t.Helper()
+	got := &capturedRequest{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		*got = capturedRequest{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: data}
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, got
+}
+
+func decodeJSON(t *testing.T, data []byte) map[string]interface{} {
+	t.Helper()
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("request body is not JSON: %v\n%s", err, data)
+	}
+	return out
+}
+
+func assertJSON(t *testing.T, data []byte, want string) {
+	t.Helper()
+	if got, exp := decodeJSON(t, data), decodeJSON(t, []byte(want)); !reflect.DeepEqual(got, exp) {
+		t.Errorf("payload = %v\nwant      %v", got, exp)
+	}
+}
+
+var (
+	testImage = &Media{URL: "https://cdn.example.com/exame.jpg", ContentType: "image/jpeg"}
+	testPDF   = &Media{URL: "https://cdn.example.com/laudo.pdf", ContentType: "application/pdf", Filename: "laudo.pdf"}
+)
+
+func TestTwilioSend(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  OutboundMessage
+		want url.Values
+	}{
+		{
+			name: "text",
+			msg:  OutboundMessage{To: "+5541999998888", Body: "Olá"},
+			want: url.Values{"To": {"whatsapp:+5541999998888"}, "From": {"whatsapp:+554100000000"}, "Body": {"Olá"}, "StatusCallback": {"https://panel.example.com/status"}},
+		},
+		{
+			name: "template",
+			msg: OutboundMessage{To: "whatsapp:+5541999998888", Body: "Olá Ana", Template: &Template{
+				ContentID: "HX123", Params: []TemplateParam{{Name: "1", Value: "Ana"}},
+			}},
+			want: url.Values{"To": {"whatsapp:+5541999998888"}, "From": {"whatsapp:+554100000000"}, "ContentSid": {"HX123"}, "ContentVariables": {`{"1":"Ana"}`}, "StatusCallback": {"https://panel.example.com/status"}},
+		},
+		{
+			name: "media",
+			msg:  OutboundMessage{To: "+5541999998888", Body: "Seu exame", Media: testImage},
+			want: url.Values{"To": {"whatsapp:+5541999998888"}, "From": {"whatsapp:+554100000000"}, "Body": {"Seu exame"}, "MediaUrl": {testImage.URL}, "StatusCallback": {"https://panel.example.com/status"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, got := providerServer(t, http.StatusCreated, `{"sid":"SM123","status":"queued"}`)
+			p, err := NewTwilioProvider(TwilioConfig{
+				AccountSID:        "AC123",
+				AuthToken:         "secret",
+				APIURL:            srv.URL + "/2010-04-01/Accounts/AC123/Messages.json",
+				From:              "whatsapp:+554100000000",
+				StatusCallbackURL: "https://panel.example.com/status",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := p.Send(context.Background(), tt.msg)
+			if err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			if res.Provider != "twilio" || res.ProviderMessageID != "SM123" || res.Status != StatusQueued {
+				t.Errorf("result = %+v", res)
+			}
+			if got.Method != http.MethodPost || got.Path != "/2010-04-01/Accounts/AC123/Messages.json" {
+				t.Errorf("request = %s %s", got.Method, got.Path)
+			}
+			if user, pass, ok := (&http.Request{Header: got.Header}).BasicAuth(); !ok || user != "AC123" || pass != "secret" {
+				t.Errorf("basic auth = %q %q %v", user, pass, ok)
+			}
+			form, err := url.ParseQuery(string(got.Body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(form, tt.want) {
+				t.Errorf("form = %v\nwant   %v", form, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetaSend(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  OutboundMessage
+		want string
+	}{
+		{
+			name: "text",
+			msg:  OutboundMessage{To: "whatsapp:+5541999998888", Body: "Olá"},
+			want: `{"messaging_product":"whatsapp","to":"5541999998888","type":"text","text":{"body":"Olá"}}`,
+		},
+		{
+			name: "template",
+			msg: OutboundMessage{To: "+5541999998888", Body: "Olá Ana", Template: &Template{
+				ContentID: "lembrete_consulta", Language: "pt_BR",
+				Params: []TemplateParam{{Name: "1", Value: "Ana"}, {Name: "especialidade", Value: "Cardiologia"}},
+			}},
+			want: `{"messaging_product":"whatsapp","to":"5541999998888","type":"template","template":{
+				"name":"lembrete_consulta","language":{"code":"pt_BR"},
+				"components":[{"type":"body","parameters":[
+					{"type":"text","text":"Ana"},
+					{"type":"text","text":"Cardiologia","parameter_name":"especialidade"}]}]}}`,
+		},
+		{
+			name: "image",
+			msg:  OutboundMessage{To: "+5541999998888", Body: "Seu exame", Media: testImage},
+			want: `{"messaging_product":"whatsapp","to":"5541999998888","type":"image","image":{"link":"https://cdn.example.com/exame.jpg","caption":"Seu exame"}}`,
+		},
+		{
+			name: "document",
+			msg:  OutboundMessage{To: "+5541999998888", Media: testPDF},
+			want: `{"messaging_product":"whatsapp","to":"5541999998888","type":"document","document":{"link":"https://cdn.example.com/laudo.pdf","filename":"laudo.pdf"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, got := providerServer(t, http.StatusOK, `{"messages":[{"id":"wamid.ABC","message_status":"accepted"}]}`)
+			p, err := NewMetaProvider(MetaConfig{AccessToken: "token", PhoneNumberID: "1234", GraphURL: srv.URL + "/v19.0/"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := p.Send(context.Background(), tt.msg)
+			if err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			if res.Provider != "meta" || res.ProviderMessageID != "wamid.ABC" || res.Status != StatusQueued {
+				t.Errorf("result = %+v", res)
+			}
+			if got.Path != "/v19.0/1234/messages" {
+				t.Errorf("path = %s", got.Path)
+			}
+			if h := got.Header.Get("Authorization"); h != "Bearer token" {
+				t.Errorf("Authorization = %q", h)
+			}
+			if h := got.Header.Get("Content-Type"); h != "application/json" {
+				t.Errorf("Content-Type = %q", h)
+			}
+			assertJSON(t, got.Body, tt.want)
+		})
+	}
+}
+
+func TestEvolutionSend(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  OutboundMessage
+		path string
+		want string
+	}{
+		{
+			name: "text",
+			msg:  OutboundMessage{To: "whatsapp:+5541999998888", Body: "Olá"},
+			path: "/message/sendText/clinica",
+			want: `{"number":"5541999998888","text":"Olá"}`,
+		},
+		{
+			name: "document",
+			msg:  OutboundMessage{To: "+5541999998888", Body: "Seu laudo", Media: testPDF},
+			path: "/message/sendMedia/clinica",
+			want: `{"number":"5541999998888","mediatype":"document","mimetype":"application/pdf",
+				"media":"https://cdn.example.com/laudo.pdf","caption":"Seu laudo","fileName":"laudo.pdf"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, got := providerServer(t, http.StatusCreated, `{"key":{"id":"3EB0ABC"},"status":"PENDING"}`)
+			p, err := NewEvolutionProvider(EvolutionConfig{BaseURL: srv.URL + "/", APIKey: "key", Instance: "clinica"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := p.Send(context.Background(), tt.msg)
+			if err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			if res.Provider != "evolution" || res.ProviderMessageID != "3EB0ABC" || res.Status != StatusQueued {
+				t.Errorf("result = %+v", res)
+			}
+			if got.Path != tt.path {
+				t.Errorf("path = %s, want %s", got.Path, tt.path)
+			}
+			if h := got.Header.Get("apikey"); h != "key" {
+				t.Errorf("apikey = %q", h)
+			}
+			assertJSON(t, got.Body, tt.want)
+		})
+	}
+}
+
+func TestZAPISend(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  OutboundMessage
+		path string
+		want string
+	}{
+		{
+			name: "text",
+			msg:  OutboundMessage{To: "+5541999998888", Body: "Olá"},
+			path: "/instances/INST/token/TOK/send-text",
+			want: `{"phone":"5541999998888","message":"Olá"}`,
+		},
+		{
+			name: "image",
+			msg:  OutboundMessage{To: "+5541999998888", Body: "Seu exame", Media: testImage},
+			path: "/instances/INST/token/TOK/send-image",
+			want: `{"phone":"5541999998888","image":"https://cdn.example.com/exame.jpg","caption":"Seu exame"}`,
+		},
+		{
+			name: "document",
+			msg:  OutboundMessage{To: "+5541999998888", Media: testPDF},
+			path: "/instances/INST/token/TOK/send-document/pdf",
+			want: `{"phone":"5541999998888","document":"https://cdn.example.com/laudo.pdf","fileName":"laudo.pdf","caption":""}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, got := providerServer(t, http.StatusOK, `{"zaapId":"Z1","messageId":"MSG1"}`)
+			p, err := NewZAPIProvider(ZAPIConfig{BaseURL: srv.URL, InstanceID: "INST", Token: "TOK", ClientToken: "CT"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := p.Send(context.Background(), tt.msg)
+			if err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			if res.Provider != "zapi" || res.ProviderMessageID != "MSG1" || res.Status != StatusQueued {
+				t.Errorf("result = %+v", res)
+			}
+			if got.Path != tt.path {
+				t.Errorf("path = %s, want %s", got.Path, tt.path)
+			}
+			if h := got.Header.Get("Client-Token"); h != "CT" {
+				t.Errorf("Client-Token = %q", h)
+			}
+			assertJSON(t, got.Body, tt.want)
+		})
+	}
+}
+
+// Non-2xx answers come back as a ProviderError with the vendor's body.
+func TestSendProviderError(t *testing.T) {
+	srv, _ := providerServer(t, http.StatusBadRequest, `{"error":"invalid number"}`)
+
+	twilio, _ := NewTwilioProvider(TwilioConfig{AccountSID: "AC123", AuthToken: "secret", APIURL: srv.URL})
+	meta, _ := NewMetaProvider(MetaConfig{AccessToken: "token", PhoneNumberID: "1234", GraphURL: srv.URL})
+	evolution, _ := NewEvolutionProvider(EvolutionConfig{BaseURL: srv.URL, APIKey: "key", Instance: "clinica"})
+	zapi, _ := NewZAPIProvider(ZAPIConfig{BaseURL: srv.URL, InstanceID: "INST", Token: "TOK"})
+
+	for _, p := range []MessageProvider{twilio, meta, evolution, zapi} {
+		_, err := p.Send(context.Background(), OutboundMessage{To: "+5541999998888", Body: "Olá"})
+		var perr *ProviderError
+		if !errors.As(err, &perr) {
+			t.Errorf("%s: err = %v, want ProviderError", p.Name(), err)
+			continue
+		}
+		if perr.Provider != p.Name() || perr.StatusCode != http.StatusBadRequest || perr.Body != `{"error":"invalid number"}` {
+			t.Errorf("%s: error = %+v", p.Name(), perr)
+		}
+	}
+}
+
+func TestNewProviderConfigMissing(t *testing.T) {
+	if _, err := NewTwilioProvider(TwilioConfig{AccountSID: "AC123"}); err == nil {
+		t.Error("twilio: expected an error")
+	}
+	if _, err := NewMetaProvider(MetaConfig{AccessToken: "token"}); err == nil {
+		t.Error("meta: expected an error")
+	}
+	if _, err := NewEvolutionProvider(EvolutionConfig{BaseURL: "http://evolution"}); err == nil {
+		t.Error("evolution: expected an error")
+	}
+	if _, err := NewZAPIProvider(ZAPIConfig{InstanceID: "INST"}); err == nil {
+		t.Error("zapi: expected an error")
+	}
+}
+
+func TestFakeProvider(t *testing.T) {
+	p := NewFakeProvider()
+	first := OutboundMessage{To: "+5541999998888", Body: "um"}
+	second := OutboundMessage{To: "+5541999998888", Body: "dois"}
+
+	for i, msg := range []OutboundMessage{first, second} {
+		res, err := p.Send(context.Background(), msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"fake-1", "fake-2"}[i]; res.ProviderMessageID != want {
+			t.Errorf("message %d: id = %s, want %s", i+1, res.ProviderMessageID, want)
+		}
+	}
+
+	p.Err = errors.New("down")
+	if _, err := p.Send(context.Background(), first); !errors.Is(err, p.Err) {
+		t.Errorf("err = %v, want %v", err, p.Err)
+	}
+	if sent := p.Sent(); !reflect.DeepEqual(sent, []OutboundMessage{first, second}) {
+		t.Errorf("Sent() = %+v", sent)
+	}
+}
@@ -0,0 +1,129 @@
+package messaging
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Delivery statuses, normalized across providers.
+const (
+	StatusQueued    = "queued"
+	StatusSent      = "sent"
+	StatusDelivered = "delivered"
+	StatusRead      = "read"
+	StatusFailed    = "failed"
+)
+
+// StatusUpdate is a delivery receipt for a message the clinic sent.
+type StatusUpdate struct {
+	Provider          string
+	ProviderMessageID string
+	Status            string
+	ErrorCode         string
+	ErrorMessage      string
+	At                time.Time
+}
+
+// NormalizeStatus maps vendor-specific statuses onto the constants above.
+func NormalizeStatus(s string) string {
+	switch strings.ToLower(s) {
+	case "accepted", "queued", "scheduled", "sending", "pending", "server_ack":
+		return StatusQueued
+	case "sent":
+		return StatusSent
+	case "delivered", "delivery_ack":
+		return StatusDelivered
+	case "read", "played":
+		return StatusRead
+	case "failed", "undelivered", "canceled", "error":
+		return StatusFailed
+	default:
+		return StatusQueued
+	}
+}
+
+// StatusRank orders statuses so late or duplicated callbacks never move a
+// message backwards. Failed is terminal.
+func StatusRank(s string) int {
+	switch s {
+	case StatusSent:
+		return 1
+	case StatusDelivered:
+		return 2
+	case StatusRead:
+		return 3
+	case StatusFailed:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// ParseTwilioStatus reads a StatusCallback form.
+func ParseTwilioStatus(form url.Values) (StatusUpdate, bool) {
+	sid := form.Get("MessageSid")
+	status := form.Get("MessageStatus")
+	if sid == "" || status == "" {
+		return StatusUpdate{}, false
+	}
+	return StatusUpdate{
+		Provider:          "twilio",
+		ProviderMessageID: sid,
+		Status:            NormalizeStatus(status),
+		ErrorCode:         form.Get("ErrorCode"),
+		ErrorMessage:      form.Get("ErrorMessage"),
+		At:                time.Now().UTC(),
+	}, true
+}
+
+type metaStatusPayload struct {
+	Entry []struct {
+		Changes []struct {
+			Value struct {
+				Statuses []struct {
+					ID        string `json:"id"`
+					Status    string `json:"status"`
+					Timestamp string `json:"timestamp"`
+					Errors    []struct {
+						Code  int    `json:"code"`
+						Title string `json:"title"`
+					} `json:"errors"`
+				} `json:"statuses"`
+			} `json:"value"`
+		} `json:"changes"`
+	} `json:"entry"`
+}
+
+// ParseMetaStatuses extracts the delivery receipts of a Cloud API webhook payload.
+func ParseMetaStatuses(body []byte) ([]StatusUpdate, error) {
+	var p metaStatusPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+
+	var out []StatusUpdate
+	for _, e := range p.Entry {
+		for _, c := range e.Changes {
+			for _, st := range c.Value.Statuses {
+				u := StatusUpdate{
+					Provider:          "meta",
+					ProviderMessageID: st.ID,
+					Status:            NormalizeStatus(st.Status),
+					At:                time.Now().UTC(),
+				}
+				if ts, err := strconv.ParseInt(st.Timestamp, 10, 64); err == nil {
+					u.At = time.Unix(ts, 0).UTC()
+				}
+				if len(st.Errors) > 0 {
+					u.ErrorCode = strconv.Itoa(st.Errors[0].Code)
+					u.ErrorMessage = st.Errors[0].Title
+				}
+				out = append(out, u)
+			}
+		}
+	}
+	return out, nil
+}
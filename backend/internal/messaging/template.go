@@ -0,0 +1,61 @@
+package messaging
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var placeholderRe = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+// TemplateParam is one placeholder value, in the order it appears in the body.
+type TemplateParam struct {
+	Name  string
+	Value string
+}
+
+// Template carries what providers need to send an approved template.
+// ContentID is the Twilio ContentSid or the Meta template name.
+type Template struct {
+	ContentID string
+	Language  string
+	Params    []TemplateParam
+}
+
+// Placeholders lists the distinct placeholder names of body, in order.
+func Placeholders(body string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, m := range placeholderRe.FindAllStringSubmatch(body, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// RenderTemplate substitutes params into body. Every placeholder must have a
+// non-empty value.
+func RenderTemplate(body string, params map[string]string) (string, []TemplateParam, error) {
+	names := Placeholders(body)
+	ordered := make([]TemplateParam, 0, len(names))
+	var missing []string
+	for _, n := range names {
+		v := strings.TrimSpace(params[n])
+		if v == "" {
+			missing = append(missing, n)
+			continue
+		}
+		ordered = append(ordered, TemplateParam{Name: n, Value: v})
+	}
+	if len(missing) > 0 {
+		return "", nil, fmt.Errorf("missing template params: %s", strings.Join(missing, ", "))
+	}
+
+	rendered := placeholderRe.ReplaceAllStringFunc(body, func(m string) string {
+		name := placeholderRe.FindStringSubmatch(m)[1]
+		return strings.TrimSpace(params[name])
+	})
+	return rendered, ordered, nil
+}
@@ -0,0 +1,98 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const defaultTwilioFrom = "whatsapp:+554134111916"
+
+type TwilioConfig struct {
+	AccountSID string
+	AuthToken  string
+	APIURL     string
+	From       string
+	// StatusCallbackURL receives delivery receipts; empty disables them.
+	StatusCallbackURL string
+}
+
+type TwilioProvider struct {
+	cfg TwilioConfig
+}
+
+func NewTwilioProvider(cfg TwilioConfig) (*TwilioProvider, error) {
+	if cfg.AccountSID == "" || cfg.AuthToken == "" || cfg.APIURL == "" {
+		return nil, errors.New("twilio configuration missing")
+	}
+	if cfg.From == "" {
+		cfg.From = defaultTwilioFrom
+	}
+	return &TwilioProvider{cfg: cfg}, nil
+}
+
+func (p *TwilioProvider) Name() string { return "twilio" }
+
+func (p *TwilioProvider) Send(ctx context.Context, msg OutboundMessage) (SendResult, error) {
+	to := msg.To
+	if !strings.HasPrefix(to, "whatsapp:") {
+		to = "whatsapp:" + to
+	}
+
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("From", p.cfg.From)
+	if t := msg.Template; t != nil && t.ContentID != "" {
+		vars := make(map[string]string, len(t.Params))
+		for _, p := range t.Params {
+			vars[p.Name] = p.Value
+		}
+		varsJSON, _ := json.Marshal(vars)
+		form.Set("ContentSid", t.ContentID)
+		form.Set("ContentVariables", string(varsJSON))
+	} else {
+		form.Set("Body", msg.Body)
+	}
+	if msg.Media != nil {
+		form.Set("MediaUrl", msg.Media.URL)
+	}
+	if p.cfg.StatusCallbackURL != "" {
+		form.Set("StatusCallback", p.cfg.StatusCallbackURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.APIURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return SendResult{}, fmt.Errorf("failed to create twilio request: %w", err)
+	}
+	req.SetBasicAuth(p.cfg.AccountSID, p.cfg.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return SendResult{}, fmt.Errorf("twilio request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return SendResult{}, &ProviderError{Provider: p.Name(), StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var parsed struct {
+		SID    string `json:"sid"`
+		Status string `json:"status"`
+	}
+	_ = json.Unmarshal(body, &parsed)
+
+	return SendResult{
+		Provider:          p.Name(),
+		ProviderMessageID: parsed.SID,
+		Status:            NormalizeStatus(parsed.Status),
+		Raw:               rawOrNil(body),
+	}, nil
+}
@@ -0,0 +1,151 @@
+package messaging
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InboundMessage is a message a contact sent to the clinic number.
+type InboundMessage struct {
+	Provider          string
+	ProviderMessageID string
+	From              string
+	ProfileName       string
+	Body              string
+	Media             *Media
+	ReceivedAt        time.Time
+}
+
+// VerifyTwilioSignature checks X-Twilio-Signature: base64(HMAC-SHA1(authToken,
+// fullURL + each POST param name and value, sorted by name)).
+func VerifyTwilioSignature(authToken, fullURL string, params url.Values, signature string) bool {
+	if authToken == "" || signature == "" {
+		return false
+	}
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(fullURL)
+	for _, k := range keys {
+		for _, v := range params[k] {
+			b.WriteString(k)
+			b.WriteString(v)
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(b.String()))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// VerifyMetaSignature checks X-Hub-Signature-256: "sha256=" + hex(HMAC-SHA256(appSecret, body)).
+func VerifyMetaSignature(appSecret string, body []byte, header string) bool {
+	if appSecret == "" || !strings.HasPrefix(header, "sha256=") {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(header, "sha256="))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// ParseTwilioInbound reads an incoming-message webhook form.
+func ParseTwilioInbound(form url.Values) (InboundMessage, bool) {
+	from := form.Get("From")
+	sid := form.Get("MessageSid")
+	if from == "" || sid == "" {
+		return InboundMessage{}, false
+	}
+	in := InboundMessage{
+		Provider:          "twilio",
+		ProviderMessageID: sid,
+		From:              from,
+		ProfileName:       form.Get("ProfileName"),
+		Body:              form.Get("Body"),
+		ReceivedAt:        time.Now().UTC(),
+	}
+	if n, _ := strconv.Atoi(form.Get("NumMedia")); n > 0 && form.Get("MediaUrl0") != "" {
+		in.Media = &Media{
+			URL:         form.Get("MediaUrl0"),
+			ContentType: form.Get("MediaContentType0"),
+		}
+	}
+	return in, true
+}
+
+type metaWebhookPayload struct {
+	Entry []struct {
+		Changes []struct {
+			Value struct {
+				Contacts []struct {
+					WaID    string `json:"wa_id"`
+					Profile struct {
+						Name string `json:"name"`
+					} `json:"profile"`
+				} `json:"contacts"`
+				Messages []struct {
+					ID        string `json:"id"`
+					From      string `json:"from"`
+					Timestamp string `json:"timestamp"`
+					Type      string `json:"type"`
+					Text      struct {
+						Body string `json:"body"`
+					} `json:"text"`
+				} `json:"messages"`
+			} `json:"value"`
+		} `json:"changes"`
+	} `json:"entry"`
+}
+
+// ParseMetaInbound extracts the messages of a Cloud API webhook payload.
+func ParseMetaInbound(body []byte) ([]InboundMessage, error) {
+	var p metaWebhookPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+
+	var out []InboundMessage
+	for _, e := range p.Entry {
+		for _, c := range e.Changes {
+			names := make(map[string]string, len(c.Value.Contacts))
+			for _, ct := range c.Value.Contacts {
+				names[ct.WaID] = ct.Profile.Name
+			}
+			for _, m := range c.Value.Messages {
+				received := time.Now().UTC()
+				if ts, err := strconv.ParseInt(m.Timestamp, 10, 64); err == nil {
+					received = time.Unix(ts, 0).UTC()
+				}
+				out = append(out, InboundMessage{
+					Provider:          "meta",
+					ProviderMessageID: m.ID,
+					From:              "+" + m.From,
+					ProfileName:       names[m.From],
+					Body:              m.Text.Body,
+					ReceivedAt:        received,
+				})
+			}
+		}
+	}
+	return out, nil
+}
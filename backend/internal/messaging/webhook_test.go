@@ -0,0 +1,136 @@
+package messaging
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestVerifyTwilioSignature(t *testing.T) {
+	// Example from Twilio's webhook security documentation.
+	const (
+		token     = "12345"
+		fullURL   = "https://mycompany.com/myapp.php?foo=1&bar=2"
+		signature = "0/KCTR6DLpKmkAf8muzZqo1nDgQ="
+	)
+	params := url.Values{
+		"CallSid": {"CA1234567890ABCDE"},
+		"Caller":  {"+12349013030"},
+		"Digits":  {"1234"},
+		"From":    {"+12349013030"},
+		"To":      {"+18005551212"},
+	}
+	tampered := url.Values{}
+	for k, v := range params {
+		tampered[k] = v
+	}
+	tampered.Set("Digits", "4321")
+
+	tests := []struct {
+		name      string
+		token     string
+		url       string
+		params    url.Values
+		signature string
+		want      bool
+	}{
+		{"valid", token, fullURL, params, signature, true},
+		{"wrong token", "54321", fullURL, params, signature, false},
+		{"other url", token, "https://mycompany.com/myapp.php", params, signature, false},
+		{"tampered param", token, fullURL, tampered, signature, false},
+		{"no signature", token, fullURL, params, "", false},
+		{"no token configured", "", fullURL, params, signature, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyTwilioSignature(tt.token, tt.url, tt.params, tt.signature); got != tt.want {
+				t.Errorf("VerifyTwilioSignature = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyMetaSignature(t *testing.T) {
+	body := []byte(`{"object":"whatsapp_business_account","entry":[]}`)
+	mac := hmac.New(sha256.New, []byte("app-secret"))
+	mac.Write(body)
+	valid := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name   string
+		secret string
+		body   []byte
+		header string
+		want   bool
+	}{
+		{"valid", "app-secret", body, valid, true},
+		{"wrong secret", "other-secret", body, valid, false},
+		{"tampered body", "app-secret", []byte(`{"object":"whatsapp_business_account","entry":[{}]}`), valid, false},
+		{"missing prefix", "app-secret", body, valid[len("sha256="):], false},
+		{"not hex", "app-secret", body, "sha256=zz", false},
+		{"no header", "app-secret", body, "", false},
+		{"no secret configured", "", body, valid, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyMetaSignature(tt.secret, tt.body, tt.header); got != tt.want {
+				t.Errorf("VerifyMetaSignature = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTwilioInbound(t *testing.T) {
+	form := url.Values{
+		"MessageSid":        {"SM123"},
+		"From":              {"whatsapp:+5541999998888"},
+		"ProfileName":       {"Ana"},
+		"Body":              {"Oi"},
+		"NumMedia":          {"1"},
+		"MediaUrl0":         {"https://api.twilio.com/media/ME1"},
+		"MediaContentType0": {"image/jpeg"},
+	}
+	in, ok := ParseTwilioInbound(form)
+	if !ok {
+		t.Fatal("expected a message")
+	}
+	if in.Provider != "twilio" || in.ProviderMessageID != "SM123" || in.From != "whatsapp:+5541999998888" ||
+		in.ProfileName != "Ana" || in.Body != "Oi" {
+		t.Errorf("message = %+v", in)
+	}
+	if in.Media == nil || in.Media.URL != "https://api.twilio.com/media/ME1" || in.Media.ContentType != "image/jpeg" {
+		t.Errorf("media = %+v", in.Media)
+	}
+
+	form.Del("MessageSid")
+	if _, ok := ParseTwilioInbound(form); ok {
+		t.Error("a form without MessageSid is not a message")
+	}
+}
+
+func TestParseMetaInbound(t *testing.T) {
+	body := []byte(`{"entry":[{"changes":[{"value":{
+		"contacts":[{"wa_id":"5541999998888","profile":{"name":"Ana"}}],
+		"messages":[{"id":"wamid.1","from":"5541999998888","timestamp":"1714564800","type":"text","text":{"body":"PARAR"}}]
+	}}]}]}`)
+
+	msgs, err := ParseMetaInbound(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("got %d messages, want 1", len(msgs))
+	}
+	in := msgs[0]
+	if in.Provider != "meta" || in.ProviderMessageID != "wamid.1" || in.From != "+5541999998888" ||
+		in.ProfileName != "Ana" || in.Body != "PARAR" || !in.ReceivedAt.Equal(time.Unix(1714564800, 0)) {
+		t.Errorf("message = %+v", in)
+	}
+
+	if _, err := ParseMetaInbound([]byte("not json")); err == nil {
+		t.Error("expected an error for an invalid body")
+	}
+}
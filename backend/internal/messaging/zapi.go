@@ -0,0 +1,96 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path"
+	"strings"
+)
+
+const defaultZAPIURL = "https://api.z-api.io"
+
+type ZAPIConfig struct {
+	BaseURL     string
+	InstanceID  string
+	Token       string
+	ClientToken string
+}
+
+// ZAPIProvider talks to the Z-API hosted service.
+type ZAPIProvider struct {
+	cfg ZAPIConfig
+}
+
+func NewZAPIProvider(cfg ZAPIConfig) (*ZAPIProvider, error) {
+	if cfg.InstanceID == "" || cfg.Token == "" {
+		return nil, errors.New("z-api configuration missing")
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultZAPIURL
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	return &ZAPIProvider{cfg: cfg}, nil
+}
+
+func (p *ZAPIProvider) Name() string { return "zapi" }
+
+func (p *ZAPIProvider) endpoint(action string) string {
+	return p.cfg.BaseURL + "/instances/" + p.cfg.InstanceID + "/token/" + p.cfg.Token + "/" + action
+}
+
+func (p *ZAPIProvider) headers() map[string]string {
+	h := map[string]string{}
+	if p.cfg.ClientToken != "" {
+		h["Client-Token"] = p.cfg.ClientToken
+	}
+	return h
+}
+
+func (p *ZAPIProvider) Send(ctx context.Context, msg OutboundMessage) (SendResult, error) {
+	action := "send-text"
+	payload := map[string]interface{}{
+		"phone":   stripWhatsAppPrefix(msg.To),
+		"message": msg.Body,
+	}
+	if m := msg.Media; m != nil {
+		payload = map[string]interface{}{"phone": stripWhatsAppPrefix(msg.To)}
+		switch m.Kind() {
+		case "image", "video", "audio":
+			action = "send-" + m.Kind()
+			payload[m.Kind()] = m.URL
+			payload["caption"] = msg.Body
+		default:
+			ext := strings.TrimPrefix(path.Ext(m.Filename), ".")
+			if ext == "" {
+				ext = "pdf"
+			}
+			action = "send-document/" + ext
+			payload["document"] = m.URL
+			payload["fileName"] = m.Filename
+			payload["caption"] = msg.Body
+		}
+	}
+
+	body, err := postJSON(ctx, p.Name(), p.endpoint(action), p.headers(), payload)
+	if err != nil {
+		return SendResult{}, err
+	}
+
+	var parsed struct {
+		MessageID string `json:"messageId"`
+		ID        string `json:"id"`
+	}
+	_ = json.Unmarshal(body, &parsed)
+
+	id := parsed.MessageID
+	if id == "" {
+		id = parsed.ID
+	}
+	return SendResult{
+		Provider:          p.Name(),
+		ProviderMessageID: id,
+		Status:            StatusQueued,
+		Raw:               rawOrNil(body),
+	}, nil
+}
@@ -0,0 +1,74 @@
+// Package outbox holds the delivery rules of the outbound message queue:
+// one send attempt through a provider, and whether a failed row is retried
+// (and when) or dead-lettered. Storing the rows is up to the caller.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"bestdoctors_service/internal/messaging"
+)
+
+// Retry is the retry schedule of outbox rows.
+type Retry struct {
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// Failure is the state of a row after a failed attempt.
+type Failure struct {
+	// Attempts counts the failed attempt.
+	Attempts int
+	// Dead rows are not retried.
+	Dead bool
+	// NextAttemptAt is set for rows that are retried.
+	NextAttemptAt time.Time
+}
+
+// Backoff is the wait after the given attempt: BaseBackoff, doubled for
+// every attempt after the first, up to MaxBackoff.
+func (r Retry) Backoff(attempt int) time.Duration {
+	d := r.BaseBackoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= r.MaxBackoff {
+			return r.MaxBackoff
+		}
+	}
+	return d
+}
+
+// Fail counts a failed attempt on a row that had attempts so far. The row
+// is dead once it used maxAttempts or the provider rejected it for good.
+func (r Retry) Fail(attempts, maxAttempts int, cause error, now time.Time) Failure {
+	f := Failure{Attempts: attempts + 1}
+	if f.Attempts >= maxAttempts || Permanent(cause) {
+		f.Dead = true
+		return f
+	}
+	f.NextAttemptAt = now.Add(r.Backoff(f.Attempts))
+	return f
+}
+
+// Attempt sends msg through p once. When the provider fails, the error
+// comes back with the row's next state.
+func (r Retry) Attempt(ctx context.Context, p messaging.MessageProvider, msg messaging.OutboundMessage, attempts, maxAttempts int, now time.Time) (messaging.SendResult, Failure, error) {
+	result, err := p.Send(ctx, msg)
+	if err != nil {
+		return messaging.SendResult{}, r.Fail(attempts, maxAttempts, err, now), err
+	}
+	return result, Failure{}, nil
+}
+
+// Permanent reports provider rejections that retrying won't fix (4xx
+// other than 429).
+func Permanent(err error) bool {
+	var perr *messaging.ProviderError
+	if !errors.As(err, &perr) {
+		return false
+	}
+	return perr.StatusCode >= 400 && perr.StatusCode < 500 && perr.StatusCode != http.StatusTooManyRequests
+}
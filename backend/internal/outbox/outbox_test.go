@@ -0,0 +1,152 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bestdoctors_service/internal/messaging"
+)
+
+var testRetry = Retry{BaseBackoff: 5 * time.Second, MaxBackoff: 30 * time.Minute}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{8, 640 * time.Second},
+		{10, 30 * time.Minute},
+		{50, 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := testRetry.Backoff(tt.attempt); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestAttemptSent(t *testing.T) {
+	fake := messaging.NewFakeProvider()
+	msg := messaging.OutboundMessage{To: "+5541999998888", Body: "Olá"}
+
+	result, _, err := testRetry.Attempt(context.Background(), fake, msg, 0, 8, time.Now())
+	if err != nil {
+		t.Fatalf("Attempt: %v", err)
+	}
+	if result.Provider != "fake" || result.ProviderMessageID != "fake-1" || result.Status != messaging.StatusQueued {
+		t.Errorf("result = %+v", result)
+	}
+	if sent := fake.Sent(); len(sent) != 1 || sent[0] != msg {
+		t.Errorf("provider got %+v, want [%+v]", sent, msg)
+	}
+}
+
+// A row that keeps failing is retried with growing backoff and
+// dead-lettered on its last attempt; nothing reaches the provider.
+func TestAttemptRetryThenDeadLetter(t *testing.T) {
+	fake := messaging.NewFakeProvider()
+	fake.Err = errors.New("connection reset")
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	msg := messaging.OutboundMessage{To: "+5541999998888", Body: "Olá"}
+
+	const maxAttempts = 4
+	attempts := 0
+	for i, wait := range []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second} {
+		_, f, err := testRetry.Attempt(context.Background(), fake, msg, attempts, maxAttempts, now)
+		if err == nil {
+			t.Fatalf("attempt %d: expected an error", i+1)
+		}
+		if f.Dead || f.Attempts != attempts+1 || !f.NextAttemptAt.Equal(now.Add(wait)) {
+			t.Fatalf("attempt %d: failure = %+v, want retry at %s", i+1, f, now.Add(wait))
+		}
+		attempts = f.Attempts
+	}
+
+	_, f, err := testRetry.Attempt(context.Background(), fake, msg, attempts, maxAttempts, now)
+	if err == nil || !f.Dead || f.Attempts != maxAttempts || !f.NextAttemptAt.IsZero() {
+		t.Fatalf("last attempt: failure = %+v, err = %v, want dead", f, err)
+	}
+	if sent := fake.Sent(); len(sent) != 0 {
+		t.Errorf("provider accepted %d messages", len(sent))
+	}
+
+	// The provider recovers before the row runs out of attempts.
+	fake.Err = nil
+	if _, _, err := testRetry.Attempt(context.Background(), fake, msg, 2, maxAttempts, now); err != nil {
+		t.Fatalf("attempt after recovery: %v", err)
+	}
+	if sent := fake.Sent(); len(sent) != 1 {
+		t.Errorf("provider accepted %d messages, want 1", len(sent))
+	}
+}
+
+// Provider answers decide between retrying and dead-lettering right away.
+func TestAttemptProviderStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		dead   bool
+	}{
+		{http.StatusInternalServerError, false},
+		{http.StatusBadGateway, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusBadRequest, true},
+		{http.StatusUnauthorized, true},
+		{http.StatusNotFound, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.status), func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, `{"message":"rejected"}`)
+			}))
+			defer srv.Close()
+
+			p, err := messaging.NewTwilioProvider(messaging.TwilioConfig{
+				AccountSID: "AC123", AuthToken: "secret", APIURL: srv.URL,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			now := time.Now()
+			_, f, err := testRetry.Attempt(context.Background(), p, messaging.OutboundMessage{To: "+5541999998888", Body: "Olá"}, 0, 8, now)
+
+			var perr *messaging.ProviderError
+			if !errors.As(err, &perr) || perr.StatusCode != tt.status {
+				t.Fatalf("err = %v, want ProviderError %d", err, tt.status)
+			}
+			if f.Dead != tt.dead || f.Attempts != 1 {
+				t.Errorf("failure = %+v, want dead=%v", f, tt.dead)
+			}
+			if !tt.dead && !f.NextAttemptAt.Equal(now.Add(5*time.Second)) {
+				t.Errorf("next attempt at %s, want %s", f.NextAttemptAt, now.Add(5*time.Second))
+			}
+		})
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&messaging.ProviderError{StatusCode: 400}, true},
+		{fmt.Errorf("send: %w", &messaging.ProviderError{StatusCode: 403}), true},
+		{&messaging.ProviderError{StatusCode: 429}, false},
+		{&messaging.ProviderError{StatusCode: 503}, false},
+		{errors.New("timeout"), false},
+	}
+	for _, tt := range tests {
+		if got := Permanent(tt.err); got != tt.want {
+			t.Errorf("Permanent(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Package phonenum normalizes WhatsApp numbers to E.164, with the Brazilian
+// rules the leads' numbers need: national numbers without the country
+// code, the trunk "0" and carrier codes, DDD validation and the mobile 9th
+// digit that WhatsApp often leaves out.
+package phonenum
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	brazilCode     = "55"
+	whatsappPrefix = "whatsapp:"
+)
+
+var (
+	ErrEmpty   = errors.New("phone number is empty")
+	ErrInvalid = errors.New("phone number is not a valid E.164 number")
+	ErrDDD     = errors.New("unknown Brazilian area code (DDD)")
+	ErrBRShape = errors.New("number must have a DDD plus 8 or 9 digits")
+)
+
+// validDDD lists the Brazilian area codes in use.
+var validDDD = map[string]bool{}
+
+func init() {
+	for _, d := range strings.Fields(`
+		11 12 13 14 15 16 17 18 19 21 22 24 27 28
+		31 32 33 34 35 37 38 41 42 43 44 45 46 47 48 49
+		51 53 54 55 61 62 63 64 65 66 67 68 69
+		71 73 74 75 77 79 81 82 83 84 85 86 87 88 89
+		91 92 93 94 95 96 97 98 99`) {
+		validDDD[d] = true
+	}
+}
+
+func digitsOf(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Normalize returns raw as E.164 ("+5541999998888"). It accepts the
+// "whatsapp:" prefix, punctuation and spaces, "+"/"00" international
+// prefixes and Brazilian national formats ("(41) 99999-8888",
+// "041 9999-8888", "0 15 41 99999-8888"). Numbers without an international
+// prefix are taken as Brazilian. Brazilian mobiles missing the 9th digit
+// get it back.
+func Normalize(raw string) (string, error) {
+	s := strings.TrimSpace(raw)
+	if len(s) >= len(whatsappPrefix) && strings.EqualFold(s[:len(whatsappPrefix)], whatsappPrefix) {
+		s = strings.TrimSpace(s[len(whatsappPrefix):])
+	}
+	international := strings.HasPrefix(s, "+")
+	d := digitsOf(s)
+	if d == "" {
+		return "", ErrEmpty
+	}
+	if !international && strings.HasPrefix(d, "00") {
+		international = true
+		d = d[2:]
+	}
+
+	switch {
+	case international:
+	case strings.HasPrefix(d, brazilCode) && (len(d) == 12 || len(d) == 13):
+		// Already has the country code, just no "+".
+	default:
+		if strings.HasPrefix(d, "0") {
+			d = d[1:]
+			// Carrier selection code: 0 + 2-digit carrier + DDD + number.
+			if len(d) == 12 || len(d) == 13 {
+				d = d[2:]
+			}
+		}
+		d = brazilCode + d
+	}
+
+	if strings.HasPrefix(d, brazilCode) {
+		national, err := normalizeBrazil(d[len(brazilCode):])
+		if err != nil {
+			return "", err
+		}
+		return "+" + brazilCode + national, nil
+	}
+	if len(d) < 8 || len(d) > 15 || d[0] == '0' {
+		return "", ErrInvalid
+	}
+	return "+" + d, nil
+}
+
+// normalizeBrazil checks DDD + subscriber number and restores the mobile
+// 9th digit.
+func normalizeBrazil(n string) (string, error) {
+	if len(n) != 10 && len(n) != 11 {
+		return "", ErrBRShape
+	}
+	ddd, sub := n[:2], n[2:]
+	if !validDDD[ddd] {
+		return "", ErrDDD
+	}
+	switch {
+	case len(sub) == 9 && sub[0] != '9':
+		return "", ErrBRShape
+	case len(sub) == 8 && sub[0] >= '6':
+		// Mobile written the old way (or as WhatsApp reports it).
+		sub = "9" + sub
+	case len(sub) == 8 && sub[0] < '2':
+		return "", ErrBRShape
+	}
+	return ddd + sub, nil
+}
+
+// WhatsApp returns the provider address for an E.164 number
+// ("whatsapp:+5541999998888").
+func WhatsApp(e164 string) string {
+	return whatsappPrefix + e164
+}
+
+// Key is the digits-only form used as a lookup key (contacts, consent).
+// Numbers that cannot be normalized fall back to their plain digits so
+// they still match themselves.
+func Key(raw string) string {
+	if n, err := Normalize(raw); err == nil {
+		return n[1:]
+	}
+	return digitsOf(raw)
+}
+
+// Variants lists the ways a number may be stored by writers we don't
+// control (n8n, older rows): E.164, bare digits, the "whatsapp:" form and,
+// for Brazilian mobiles, the same without the 9th digit.
+func Variants(raw string) []string {
+	n, err := Normalize(raw)
+	if err != nil {
+		return []string{raw}
+	}
+	out := []string{n, n[1:], WhatsApp(n)}
+	if strings.HasPrefix(n, "+"+brazilCode) && len(n) == 14 {
+		old := n[:5] + n[6:]
+		out = append(out, old, old[1:], WhatsApp(old))
+	}
+	for _, v := range out {
+		if v == raw {
+			return out
+		}
+	}
+	return append(out, raw)
+}
@@ -0,0 +1,123 @@
+package phonenum
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+		err  error
+	}{
+		{"e164", "+5541999998888", "+5541999998888", nil},
+		{"e164 with punctuation", "+55 (41) 99999-8888", "+5541999998888", nil},
+		{"whatsapp prefix", "whatsapp:+5541999998888", "+5541999998888", nil},
+		{"whatsapp prefix any case", " WhatsApp: +55 41 99999-8888 ", "+5541999998888", nil},
+		{"national mobile", "(41) 99999-8888", "+5541999998888", nil},
+		{"country code without plus", "5541999998888", "+5541999998888", nil},
+		{"trunk zero", "041 99999-8888", "+5541999998888", nil},
+
+		// Mobiles missing the 9th digit get it back.
+		{"9th digit national", "41 9999-8888", "+5541999998888", nil},
+		{"9th digit e164", "+55 41 9999-8888", "+5541999998888", nil},
+		{"9th digit country code without plus", "554189998888", "+5541989998888", nil},
+		{"9th digit from 6", "11 6123-4567", "+5511961234567", nil},
+		{"landline keeps 8 digits", "(41) 3333-4444", "+554133334444", nil},
+		{"landline e164", "+554133334444", "+554133334444", nil},
+
+		// 0 + carrier code + DDD + number.
+		{"carrier code mobile", "0 15 41 99999-8888", "+5541999998888", nil},
+		{"carrier code old mobile", "0 21 41 9999-8888", "+5541999998888", nil},
+		{"carrier code landline", "0xx15 41 3333-4444", "+554133334444", nil},
+
+		// DDD 55 (RS) must not be taken for the country code.
+		{"ddd 55 mobile", "(55) 99999-8888", "+5555999998888", nil},
+		{"ddd 55 old mobile", "55 9999-8888", "+5555999998888", nil},
+		{"ddd 55 landline", "55 3222-1111", "+555532221111", nil},
+		{"ddd 55 with country code", "5555999998888", "+5555999998888", nil},
+		{"ddd 55 old mobile with country code", "555599998888", "+5555999998888", nil},
+
+		// "00" international prefix.
+		{"00 brazil", "0055 41 99999-8888", "+5541999998888", nil},
+		{"00 foreign", "00 1 415 555 2671", "+14155552671", nil},
+		{"plus foreign", "+1 (415) 555-2671", "+14155552671", nil},
+
+		{"empty", "", "", ErrEmpty},
+		{"only prefix", "whatsapp:", "", ErrEmpty},
+		{"no digits", "n/a", "", ErrEmpty},
+		{"unknown ddd", "(20) 99999-8888", "", ErrDDD},
+		{"9 digits not starting with 9", "41 88888-7777", "", ErrBRShape},
+		{"8 digits starting with 1", "41 1234-5678", "", ErrBRShape},
+		{"too short", "12345", "", ErrBRShape},
+		{"foreign too short", "+123", "", ErrInvalid},
+		{"foreign too long", "+1234567890123456", "", ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.raw)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Normalize(%q) error = %v, want %v", tt.raw, err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"+5541999998888", "5541999998888"},
+		{"whatsapp:+5541999998888", "5541999998888"},
+		{"41 9999-8888", "5541999998888"},
+		{"0 15 41 99999-8888", "5541999998888"},
+		{"(55) 9999-8888", "5555999998888"},
+		{"00 1 415 555 2671", "14155552671"},
+		// Unnormalizable numbers fall back to their digits.
+		{"(20) 99999-8888", "20999998888"},
+		{"ramal 12", "12"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Key(tt.raw); got != tt.want {
+			t.Errorf("Key(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestVariants(t *testing.T) {
+	mobile := []string{
+		"+5541999998888", "5541999998888", "whatsapp:+5541999998888",
+		"+554199998888", "554199998888", "whatsapp:+554199998888",
+	}
+
+	tests := []struct {
+		name string
+		raw  string
+		want []string
+	}{
+		{"mobile e164", "+5541999998888", mobile},
+		{"mobile stored without 9th digit", "554199998888", mobile},
+		{"mobile national keeps raw", "(41) 99999-8888", append(append([]string{}, mobile...), "(41) 99999-8888")},
+		{"landline", "+554133334444", []string{"+554133334444", "554133334444", "whatsapp:+554133334444"}},
+		{"foreign", "whatsapp:+14155552671", []string{"+14155552671", "14155552671", "whatsapp:+14155552671"}},
+		{"invalid", "(20) 99999-8888", []string{"(20) 99999-8888"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Variants(tt.raw); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Variants(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
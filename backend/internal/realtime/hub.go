@@ -0,0 +1,66 @@
+// Package realtime fans database change notifications out to connected
+// panel clients.
+package realtime
+
+import "sync"
+
+// Event is what SSE clients receive.
+type Event struct {
+	Type      string      `json:"type"`
+	SessionID string      `json:"session_id"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+// Subscriber receives events for one session, or for all sessions when
+// SessionID is empty.
+type Subscriber struct {
+	C         chan Event
+	SessionID string
+}
+
+// Hub keeps the subscribers connected to this replica.
+type Hub struct {
+	mu   sync.RWMutex
+	subs map[*Subscriber]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: map[*Subscriber]struct{}{}}
+}
+
+func (h *Hub) Subscribe(sessionID string) *Subscriber {
+	s := &Subscriber{C: make(chan Event, 64), SessionID: sessionID}
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+func (h *Hub) Unsubscribe(s *Subscriber) {
+	h.mu.Lock()
+	delete(h.subs, s)
+	h.mu.Unlock()
+}
+
+// Publish delivers e to every matching subscriber. Slow clients miss events
+// instead of blocking the others; they catch up through the delta endpoints.
+func (h *Hub) Publish(e Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for s := range h.subs {
+		if s.SessionID != "" && s.SessionID != e.SessionID {
+			continue
+		}
+		select {
+		case s.C <- e:
+		default:
+		}
+	}
+}
+
+// Len returns the number of connected subscribers.
+func (h *Hub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs)
+}
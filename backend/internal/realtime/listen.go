@@ -0,0 +1,71 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Channel is the NOTIFY channel written by the triggers in
+// migrations/011_create_realtime_triggers.sql.
+const Channel = "bestdoctors_events"
+
+// Notification is the payload sent by those triggers.
+type Notification struct {
+	Table           string `json:"table"`
+	Op              string `json:"op"`
+	ID              uint   `json:"id"`
+	SessionID       string `json:"session_id"`
+	AIActiveChanged bool   `json:"ai_active_changed"`
+}
+
+// Listen holds a dedicated connection LISTENing on Channel and calls handle
+// for each notification, reconnecting with backoff until ctx is cancelled.
+// Every replica listens, so all of them see every change.
+func Listen(ctx context.Context, dsn string, handle func(Notification)) {
+	delay := time.Second
+	for ctx.Err() == nil {
+		err := listenOnce(ctx, dsn, handle, func() { delay = time.Second })
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("❌ Realtime listener: %v (retrying in %s)", err, delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay < time.Minute {
+			delay *= 2
+		}
+	}
+}
+
+func listenOnce(ctx context.Context, dsn string, handle func(Notification), connected func()) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return err
+	}
+	connected()
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var msg Notification
+		if err := json.Unmarshal([]byte(n.Payload), &msg); err != nil {
+			log.Printf("❌ Realtime listener: bad payload %q", n.Payload)
+			continue
+		}
+		handle(msg)
+	}
+}
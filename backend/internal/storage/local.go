@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore writes media to a directory served by Handler.
+type LocalStore struct {
+	dir     string
+	baseURL string
+}
+
+func NewLocalStore(dir, baseURL string) (*LocalStore, error) {
+	if baseURL == "" {
+		return nil, errors.New("MEDIA_PUBLIC_URL (or WEBHOOK_BASE_URL) is required for local media storage")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create media dir: %w", err)
+	}
+	return &LocalStore{dir: dir, baseURL: strings.TrimRight(baseURL, "/")}, nil
+}
+
+func (s *LocalStore) Put(ctx context.Context, key, contentType string, data []byte) (string, error) {
+	full := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create media dir: %w", err)
+	}
+	if err := os.WriteFile(full, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write media: %w", err)
+	}
+	return s.baseURL + "/" + key, nil
+}
+
+// Handler serves stored files without directory listings.
+func (s *LocalStore) Handler(prefix string) http.Handler {
+	fs := http.StripPrefix(prefix, http.FileServer(http.Dir(s.dir)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		fs.ServeHTTP(w, r)
+	})
+}
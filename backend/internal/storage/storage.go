@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"bestdoctors_service/internal/config"
+)
+
+// Store keeps uploaded media somewhere providers can download it from.
+type Store interface {
+	// Put stores data under key and returns its public URL.
+	Put(ctx context.Context, key, contentType string, data []byte) (string, error)
+}
+
+// NewStoreFromEnv builds the store selected by STORAGE_DRIVER (local or s3).
+func NewStoreFromEnv() (Store, error) {
+	switch strings.ToLower(config.Env("STORAGE_DRIVER")) {
+	case "", "local":
+		dir := config.Env("MEDIA_DIR")
+		if dir == "" {
+			dir = "./media"
+		}
+		base := config.Env("MEDIA_PUBLIC_URL")
+		if base == "" {
+			if wb := config.Env("WEBHOOK_BASE_URL"); wb != "" {
+				base = strings.TrimRight(wb, "/") + "/media"
+			}
+		}
+		return NewLocalStore(dir, base)
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:  config.Env("S3_ENDPOINT"),
+			Region:    config.Env("S3_REGION"),
+			Bucket:    config.Env("S3_BUCKET"),
+			AccessKey: config.Env("S3_ACCESS_KEY"),
+			SecretKey: config.Env("S3_SECRET_KEY"),
+			PublicURL: config.Env("S3_PUBLIC_URL"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q", config.Env("STORAGE_DRIVER"))
+	}
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// NewKey returns an unguessable object key that keeps the original file name.
+func NewKey(filename string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate media key: %w", err)
+	}
+	name := unsafeNameChars.ReplaceAllString(path.Base(filename), "_")
+	if name == "" || name == "." || name == "_" {
+		name = "file"
+	}
+	return hex.EncodeToString(b) + "/" + name, nil
+}
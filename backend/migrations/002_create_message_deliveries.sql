@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS message_deliveries (
+    id SERIAL PRIMARY KEY,
+    provider VARCHAR(20) NOT NULL,
+    provider_message_id VARCHAR(128) UNIQUE NOT NULL,
+    session_id VARCHAR(255) NOT NULL,
+    chat_history_id INTEGER,
+    recipient VARCHAR(64),
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    error_code VARCHAR(32),
+    error_message TEXT,
+    delivered_at TIMESTAMP,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_deliveries_session_id ON message_deliveries(session_id);
+CREATE INDEX IF NOT EXISTS idx_message_deliveries_chat_history_id ON message_deliveries(chat_history_id);
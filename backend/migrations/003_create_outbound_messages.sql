@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS outbound_messages (
+    id SERIAL PRIMARY KEY,
+    idempotency_key VARCHAR(255) UNIQUE,
+    session_id VARCHAR(255) NOT NULL,
+    recipient VARCHAR(64) NOT NULL,
+    body TEXT NOT NULL,
+    vars JSONB,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 8,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    provider VARCHAR(20),
+    provider_message_id VARCHAR(128),
+    chat_history_id INTEGER,
+    sent_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbound_messages_due ON outbound_messages(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_outbound_messages_session_id ON outbound_messages(session_id);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS message_templates (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    language VARCHAR(10) NOT NULL DEFAULT 'pt_BR',
+    body TEXT NOT NULL,
+    provider_content_sid VARCHAR(128),
+    category VARCHAR(30) DEFAULT 'MARKETING',
+    is_active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (name, language)
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_templates_is_active ON message_templates(is_active);
+
+ALTER TABLE outbound_messages ADD COLUMN IF NOT EXISTS template_id INTEGER REFERENCES message_templates(id);
+ALTER TABLE outbound_messages ADD COLUMN IF NOT EXISTS template_params JSONB;
@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS campaigns (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    template_id INTEGER NOT NULL REFERENCES message_templates(id),
+    template_params JSONB,
+    filters JSONB,
+    status VARCHAR(20) NOT NULL DEFAULT 'scheduled',
+    scheduled_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    rate_per_minute INTEGER NOT NULL DEFAULT 20,
+    total_recipients INTEGER NOT NULL DEFAULT 0,
+    created_by INTEGER REFERENCES users(id),
+    last_batch_at TIMESTAMP,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_campaigns_status ON campaigns(status, scheduled_at);
+
+CREATE TABLE IF NOT EXISTS campaign_recipients (
+    id SERIAL PRIMARY KEY,
+    campaign_id INTEGER NOT NULL REFERENCES campaigns(id) ON DELETE CASCADE,
+    session_id VARCHAR(255) NOT NULL,
+    phone VARCHAR(64) NOT NULL,
+    lead_name VARCHAR(255),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    outbound_message_id INTEGER REFERENCES outbound_messages(id),
+    error TEXT,
+    sent_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (campaign_id, session_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_campaign_recipients_status ON campaign_recipients(campaign_id, status);
+
+ALTER TABLE outbound_messages ADD COLUMN IF NOT EXISTS campaign_id INTEGER REFERENCES campaigns(id);
@@ -0,0 +1,3 @@
+ALTER TABLE outbound_messages ADD COLUMN IF NOT EXISTS send_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_outbound_messages_scheduled ON outbound_messages(session_id, send_at) WHERE send_at IS NOT NULL;
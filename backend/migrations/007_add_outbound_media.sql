@@ -0,0 +1 @@
+ALTER TABLE outbound_messages ADD COLUMN IF NOT EXISTS media JSONB;
@@ -0,0 +1,22 @@
+-- Current WhatsApp consent per phone (digits only, e.g. 5541999999999).
+-- Phones without a row have never opted out.
+CREATE TABLE IF NOT EXISTS contact_consents (
+    phone VARCHAR(32) PRIMARY KEY,
+    status VARCHAR(20) NOT NULL,
+    updated_at TIMESTAMP DEFAULT NOW(),
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Append-only log of opt-out/opt-in events, kept as LGPD evidence.
+CREATE TABLE IF NOT EXISTS consent_events (
+    id SERIAL PRIMARY KEY,
+    phone VARCHAR(32) NOT NULL,
+    event VARCHAR(20) NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    session_id VARCHAR(255),
+    message TEXT,
+    user_id INTEGER,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_consent_events_phone ON consent_events(phone, created_at);
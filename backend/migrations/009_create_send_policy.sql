@@ -0,0 +1,17 @@
+-- Clinic-wide sending rules (single row, id = 1).
+-- business_days uses Go/Postgres weekday numbers: 0 = Sunday ... 6 = Saturday.
+-- max_proactive = 0 disables the per-phone limit.
+CREATE TABLE IF NOT EXISTS send_policy (
+    id INTEGER PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    timezone VARCHAR(64) NOT NULL DEFAULT 'America/Sao_Paulo',
+    business_start VARCHAR(5) NOT NULL DEFAULT '08:00',
+    business_end VARCHAR(5) NOT NULL DEFAULT '20:00',
+    business_days VARCHAR(20) NOT NULL DEFAULT '1,2,3,4,5,6',
+    max_proactive INTEGER NOT NULL DEFAULT 3,
+    proactive_window_hours INTEGER NOT NULL DEFAULT 24,
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+INSERT INTO send_policy (id) VALUES (1) ON CONFLICT (id) DO NOTHING;
+
+CREATE INDEX IF NOT EXISTS idx_outbound_messages_proactive_sent ON outbound_messages(sent_at) WHERE template_id IS NOT NULL;
@@ -0,0 +1,3 @@
+-- Panel user who sent a manual message; copied into the ChatHistory row as "sender".
+ALTER TABLE outbound_messages ADD COLUMN IF NOT EXISTS sender_user_id INTEGER;
+ALTER TABLE outbound_messages ADD COLUMN IF NOT EXISTS sender_username VARCHAR(255);
@@ -0,0 +1,38 @@
+-- Push changes to the backend replicas (LISTEN bestdoctors_events).
+-- Payloads only carry keys; the backend loads the row itself, which keeps
+-- them well under the 8000-byte NOTIFY limit. Rows written by n8n are
+-- covered too.
+
+CREATE OR REPLACE FUNCTION notify_chat_history() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('bestdoctors_events', json_build_object(
+        'table', TG_TABLE_NAME,
+        'op', TG_OP,
+        'id', NEW.id,
+        'session_id', NEW.session_id
+    )::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_session_phone() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('bestdoctors_events', json_build_object(
+        'table', TG_TABLE_NAME,
+        'op', TG_OP,
+        'session_id', NEW.session_id,
+        'ai_active_changed', TG_OP = 'UPDATE' AND OLD.ai_active IS DISTINCT FROM NEW.ai_active
+    )::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_notify_chat_history ON n8n_chat_histories;
+CREATE TRIGGER trg_notify_chat_history
+    AFTER INSERT ON n8n_chat_histories
+    FOR EACH ROW EXECUTE FUNCTION notify_chat_history();
+
+DROP TRIGGER IF EXISTS trg_notify_session_phone ON session_phones;
+CREATE TRIGGER trg_notify_session_phone
+    AFTER INSERT OR UPDATE ON session_phones
+    FOR EACH ROW EXECUTE FUNCTION notify_session_phone();
@@ -0,0 +1,25 @@
+-- A session with the AI turned off waits here for an attendant.
+-- queued -> assigned -> released (AI back on). At most one open handoff per session.
+CREATE TABLE IF NOT EXISTS session_handoffs (
+    id SERIAL PRIMARY KEY,
+    session_id VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    assigned_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    assigned_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    queued_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    assigned_at TIMESTAMP,
+    released_at TIMESTAMP,
+    released_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_session_handoffs_open ON session_handoffs(session_id) WHERE status <> 'released';
+CREATE INDEX IF NOT EXISTS idx_session_handoffs_assigned_user ON session_handoffs(assigned_user_id) WHERE status = 'assigned';
+
+-- Sessions already handed over before this table existed start in the queue.
+INSERT INTO session_handoffs (session_id, status, queued_at)
+SELECT session_id, 'queued', COALESCE(last_message_at, NOW())
+FROM session_phones
+WHERE ai_active = false
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,17 @@
+-- Optimistic concurrency for AI on/off: clients send the version they saw (If-Match).
+ALTER TABLE session_phones ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+-- Audit log of changes made to a session from the panel, shown inline in the chat.
+CREATE TABLE IF NOT EXISTS session_events (
+    id SERIAL PRIMARY KEY,
+    session_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(40) NOT NULL,
+    old_value TEXT,
+    new_value TEXT,
+    reason TEXT,
+    user_id INTEGER,
+    username VARCHAR(255),
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_events_session ON session_events(session_id, created_at);
@@ -0,0 +1,25 @@
+-- Private attendant notes. Kept apart from n8n_chat_histories so the AI never reads them.
+CREATE TABLE IF NOT EXISTS session_notes (
+    id SERIAL PRIMARY KEY,
+    session_id VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    author_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    author_username VARCHAR(255),
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_notes_session ON session_notes(session_id, created_at);
+
+-- Previous versions of a note, one row per edit (or delete).
+CREATE TABLE IF NOT EXISTS session_note_revisions (
+    id SERIAL PRIMARY KEY,
+    note_id INTEGER NOT NULL REFERENCES session_notes(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    edited_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    edited_username VARCHAR(255),
+    edited_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_note_revisions_note ON session_note_revisions(note_id);
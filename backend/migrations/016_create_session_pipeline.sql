@@ -0,0 +1,34 @@
+-- Sales pipeline stage per session. Sessions without a row are still "novo".
+CREATE TABLE IF NOT EXISTS session_pipeline (
+    session_id VARCHAR(255) PRIMARY KEY,
+    stage VARCHAR(20) NOT NULL DEFAULT 'novo',
+    estimated_value NUMERIC(12,2),
+    loss_reason TEXT,
+    stage_changed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    updated_username VARCHAR(255),
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    CONSTRAINT session_pipeline_stage_check
+        CHECK (stage IN ('novo', 'qualificado', 'proposta', 'fechado', 'perdido'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_pipeline_stage ON session_pipeline(stage);
+
+-- Every stage move or value change, manual or automatic. The pipeline
+-- report rebuilds past snapshots from here.
+CREATE TABLE IF NOT EXISTS pipeline_stage_changes (
+    id SERIAL PRIMARY KEY,
+    session_id VARCHAR(255) NOT NULL,
+    from_stage VARCHAR(20) NOT NULL,
+    to_stage VARCHAR(20) NOT NULL,
+    estimated_value NUMERIC(12,2),
+    loss_reason TEXT,
+    source VARCHAR(10) NOT NULL DEFAULT 'manual',
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    username VARCHAR(255),
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_pipeline_stage_changes_session ON pipeline_stage_changes(session_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_pipeline_stage_changes_created ON pipeline_stage_changes(created_at);
@@ -0,0 +1,15 @@
+-- Full-text index over n8n_chat_histories. The backend fills body with the
+-- readable text of each message (the nested content decoded); tsv is kept
+-- in sync by Postgres with Portuguese stemming.
+CREATE TABLE IF NOT EXISTS chat_search_index (
+    history_id BIGINT PRIMARY KEY REFERENCES n8n_chat_histories(id) ON DELETE CASCADE,
+    session_id VARCHAR(255) NOT NULL,
+    role VARCHAR(20),
+    body TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    tsv TSVECTOR GENERATED ALWAYS AS (to_tsvector('portuguese', body)) STORED
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_search_index_tsv ON chat_search_index USING GIN (tsv);
+CREATE INDEX IF NOT EXISTS idx_chat_search_index_session ON chat_search_index(session_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_chat_search_index_created ON chat_search_index(created_at);
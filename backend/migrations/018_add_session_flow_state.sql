@@ -0,0 +1,9 @@
+-- Deepest bot flow state reached (see detectFlowState), so the session list
+-- can filter on it in SQL. Filled in by the backend; flow_state_at is the
+-- last_message_at it was computed for.
+ALTER TABLE session_phones ADD COLUMN IF NOT EXISTS flow_state SMALLINT NOT NULL DEFAULT 0;
+ALTER TABLE session_phones ADD COLUMN IF NOT EXISTS flow_state_at TIMESTAMP;
+
+-- Keyset pagination for the session list.
+CREATE INDEX IF NOT EXISTS idx_session_phones_last_message ON session_phones(last_message_at, session_id);
+CREATE INDEX IF NOT EXISTS idx_session_phones_flow_state ON session_phones(flow_state);
@@ -0,0 +1,36 @@
+-- One row per person, keyed by the phone digits (the same key as
+-- contact_consents). Every session with that phone links here.
+CREATE TABLE IF NOT EXISTS contacts (
+    id SERIAL PRIMARY KEY,
+    phone VARCHAR(32) NOT NULL UNIQUE,
+    name VARCHAR(255),
+    email VARCHAR(255),
+    cpf VARCHAR(11),
+    convenio VARCHAR(100),
+    preferred_specialty VARCHAR(100),
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+ALTER TABLE session_phones ADD COLUMN IF NOT EXISTS contact_id INTEGER REFERENCES contacts(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_session_phones_contact ON session_phones(contact_id);
+
+-- Backfill: one contact per phone, named after its latest named session.
+INSERT INTO contacts (phone, name, created_at)
+SELECT digits,
+       (SELECT sp2.lead_name FROM session_phones sp2
+        WHERE REGEXP_REPLACE(sp2.phone, '[^0-9]', '', 'g') = t.digits AND COALESCE(sp2.lead_name, '') <> ''
+        ORDER BY sp2.last_message_at DESC LIMIT 1),
+       first_seen
+FROM (
+    SELECT REGEXP_REPLACE(phone, '[^0-9]', '', 'g') AS digits, MIN(created_at) AS first_seen
+    FROM session_phones
+    GROUP BY 1
+) t
+WHERE digits <> ''
+ON CONFLICT (phone) DO NOTHING;
+
+UPDATE session_phones sp
+SET contact_id = c.id
+FROM contacts c
+WHERE sp.contact_id IS NULL AND c.phone = REGEXP_REPLACE(sp.phone, '[^0-9]', '', 'g');
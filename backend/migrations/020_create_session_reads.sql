@@ -0,0 +1,17 @@
+-- Per-attendant read marker: the newest chat message the user has seen in
+-- a session. Unread counts are the lead's messages after it.
+CREATE TABLE IF NOT EXISTS session_reads (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    session_id VARCHAR(255) NOT NULL,
+    last_read_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (user_id, session_id)
+);
+
+-- Lead messages per session, for the unread counts in the session list.
+-- Built CONCURRENTLY so n8n keeps writing history meanwhile (psql runs each
+-- statement outside a transaction). A failed build leaves an INVALID
+-- index behind: drop it before re-running.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_chat_histories_human
+    ON n8n_chat_histories(session_id, created_at)
+    WHERE (message::jsonb ->> 'type') = 'human';
@@ -0,0 +1,34 @@
+-- Per-session figures behind the dashboard metrics, kept up to date by the
+-- backend from n8n_chat_histories so the metric endpoints can aggregate in
+-- SQL instead of reading every history. bot_* columns ignore messages typed
+-- by panel attendants (exclude_attendant). last_history_id is the newest
+-- message the row was computed from.
+CREATE TABLE IF NOT EXISTS session_metric_facts (
+    session_id VARCHAR(255) PRIMARY KEY,
+    message_count INTEGER NOT NULL DEFAULT 0,
+    human_count INTEGER NOT NULL DEFAULT 0,
+    attendant_count INTEGER NOT NULL DEFAULT 0,
+    first_message_at TIMESTAMP,
+    last_message_at TIMESTAMP,
+    max_flow_state SMALLINT NOT NULL DEFAULT 0,
+    bot_max_flow_state SMALLINT NOT NULL DEFAULT 0,
+    finalizar BOOLEAN NOT NULL DEFAULT FALSE,
+    bot_finalizar BOOLEAN NOT NULL DEFAULT FALSE,
+    recapture_index INTEGER,
+    reengaged BOOLEAN NOT NULL DEFAULT FALSE,
+    last_history_id BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_metric_facts_history ON session_metric_facts(last_history_id);
+
+-- First recapture of a session by each campaign ("Recapture - Campaign #N:").
+CREATE TABLE IF NOT EXISTS session_metric_recaptures (
+    session_id VARCHAR(255) NOT NULL REFERENCES session_metric_facts(session_id) ON DELETE CASCADE,
+    campaign_id INTEGER NOT NULL,
+    recapture_index INTEGER NOT NULL,
+    reengaged BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (session_id, campaign_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_metric_recaptures_campaign ON session_metric_recaptures(campaign_id);
@@ -0,0 +1,11 @@
+-- Cached metric responses, one row per metric and filter. params is the
+-- filter the payload was computed with, so the backend can recompute a row
+-- on its own; refreshing_until is held by the replica refreshing it.
+CREATE TABLE IF NOT EXISTS bestdoctors_metrics_cache (
+    metric_key VARCHAR(255) PRIMARY KEY,
+    payload JSONB NOT NULL,
+    last_refreshed_at TIMESTAMPTZ NOT NULL
+);
+
+ALTER TABLE bestdoctors_metrics_cache ADD COLUMN IF NOT EXISTS params JSONB;
+ALTER TABLE bestdoctors_metrics_cache ADD COLUMN IF NOT EXISTS refreshing_until TIMESTAMPTZ;
@@ -0,0 +1,18 @@
+-- Opt-out/opt-in keywords are picked up from every writer of
+-- n8n_chat_histories (the webhook and n8n) by a backend worker. history_id
+-- ties an event to the message it came from so each message is applied
+-- once; consent_scan_state holds how far the worker has read.
+ALTER TABLE consent_events ADD COLUMN IF NOT EXISTS history_id BIGINT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_consent_events_history ON consent_events(history_id);
+
+CREATE TABLE IF NOT EXISTS consent_scan_state (
+    id SMALLINT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    last_history_id BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Start at the end of the current history: replaying old keywords could
+-- undo consent changes made since in the panel.
+INSERT INTO consent_scan_state (id, last_history_id)
+SELECT 1, COALESCE(MAX(id), 0) FROM n8n_chat_histories
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,21 @@
+-- Inbound provider message ids already stored, so provider retries of the
+-- webhook are dropped. The unique key makes the check race-safe.
+CREATE TABLE IF NOT EXISTS inbound_messages (
+    provider_message_id VARCHAR(128) PRIMARY KEY,
+    provider VARCHAR(20) NOT NULL,
+    session_id VARCHAR(255),
+    chat_history_id INTEGER,
+    received_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Messages stored before this table existed keep the id in
+-- additional_kwargs.
+INSERT INTO inbound_messages (provider_message_id, provider, session_id, chat_history_id, received_at)
+SELECT message::jsonb -> 'additional_kwargs' ->> 'provider_message_id',
+       COALESCE(message::jsonb -> 'additional_kwargs' ->> 'provider', ''),
+       session_id, id, created_at
+FROM n8n_chat_histories
+WHERE (message::jsonb ->> 'type') = 'human'
+  AND COALESCE(message::jsonb -> 'additional_kwargs' ->> 'provider_message_id', '') <> ''
+ON CONFLICT (provider_message_id) DO NOTHING;
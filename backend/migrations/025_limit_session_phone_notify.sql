@@ -0,0 +1,20 @@
+-- Only notify session_phones updates the panel shows: background writes
+-- (flow_state, contact_id, phone backfills of other columns) no longer
+-- fan out a "session" event per row. The WHEN clause needs OLD, so inserts
+-- get their own trigger.
+DROP TRIGGER IF EXISTS trg_notify_session_phone ON session_phones;
+
+DROP TRIGGER IF EXISTS trg_notify_session_phone_insert ON session_phones;
+CREATE TRIGGER trg_notify_session_phone_insert
+    AFTER INSERT ON session_phones
+    FOR EACH ROW EXECUTE FUNCTION notify_session_phone();
+
+DROP TRIGGER IF EXISTS trg_notify_session_phone_update ON session_phones;
+CREATE TRIGGER trg_notify_session_phone_update
+    AFTER UPDATE ON session_phones
+    FOR EACH ROW
+    WHEN (OLD.ai_active IS DISTINCT FROM NEW.ai_active
+       OR OLD.last_message_at IS DISTINCT FROM NEW.last_message_at
+       OR OLD.lead_name IS DISTINCT FROM NEW.lead_name
+       OR OLD.phone IS DISTINCT FROM NEW.phone)
+    EXECUTE FUNCTION notify_session_phone();
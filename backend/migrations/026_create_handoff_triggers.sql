@@ -0,0 +1,44 @@
+-- Keep the handoff queue in step with ai_active whoever changes it: the
+-- panel, the backend workers or n8n writing session_phones directly.
+-- AI off queues the session, AI on releases its open handoff. The backend
+-- releases attributed handoffs itself before turning the AI back on, so
+-- released_by is only left empty for releases nobody in the panel made.
+-- Times are UTC like the ones the backend writes.
+
+CREATE OR REPLACE FUNCTION sync_session_handoff() RETURNS trigger AS $$
+BEGIN
+    IF NEW.ai_active THEN
+        UPDATE session_handoffs
+        SET status = 'released',
+            released_at = NOW() AT TIME ZONE 'UTC',
+            updated_at = NOW() AT TIME ZONE 'UTC'
+        WHERE session_id = NEW.session_id AND status <> 'released';
+    ELSE
+        INSERT INTO session_handoffs (session_id, status, queued_at)
+        VALUES (NEW.session_id, 'queued', NOW() AT TIME ZONE 'UTC')
+        ON CONFLICT (session_id) WHERE status <> 'released' DO NOTHING;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_session_handoff_insert ON session_phones;
+CREATE TRIGGER trg_session_handoff_insert
+    AFTER INSERT ON session_phones
+    FOR EACH ROW
+    WHEN (NEW.ai_active = false)
+    EXECUTE FUNCTION sync_session_handoff();
+
+DROP TRIGGER IF EXISTS trg_session_handoff_update ON session_phones;
+CREATE TRIGGER trg_session_handoff_update
+    AFTER UPDATE OF ai_active ON session_phones
+    FOR EACH ROW
+    WHEN (OLD.ai_active IS DISTINCT FROM NEW.ai_active)
+    EXECUTE FUNCTION sync_session_handoff();
+
+-- Sessions n8n turned off before this trigger existed join the queue.
+INSERT INTO session_handoffs (session_id, status, queued_at)
+SELECT session_id, 'queued', COALESCE(last_message_at, NOW() AT TIME ZONE 'UTC')
+FROM session_phones
+WHERE ai_active = false
+ON CONFLICT DO NOTHING;
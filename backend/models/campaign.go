@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Campaign states.
+const (
+	CampaignScheduled = "scheduled"
+	CampaignRunning   = "running"
+	CampaignCompleted = "completed"
+	CampaignCancelled = "cancelled"
+)
+
+// CampaignRecipient states.
+const (
+	RecipientPending = "pending"
+	RecipientQueued  = "queued"
+	RecipientSent    = "sent"
+	RecipientFailed  = "failed"
+	RecipientSkipped = "skipped"
+)
+
+type Campaign struct {
+	ID              uint           `gorm:"primaryKey;column:id" json:"id"`
+	Name            string         `gorm:"column:name" json:"name"`
+	TemplateID      uint           `gorm:"column:template_id" json:"template_id"`
+	TemplateParams  datatypes.JSON `gorm:"column:template_params" json:"template_params,omitempty"`
+	Filters         datatypes.JSON `gorm:"column:filters" json:"filters,omitempty"`
+	Status          string         `gorm:"column:status" json:"status"`
+	ScheduledAt     time.Time      `gorm:"column:scheduled_at" json:"scheduled_at"`
+	RatePerMinute   int            `gorm:"column:rate_per_minute" json:"rate_per_minute"`
+	TotalRecipients int            `gorm:"column:total_recipients" json:"total_recipients"`
+	CreatedBy       *int           `gorm:"column:created_by" json:"created_by,omitempty"`
+	LastBatchAt     *time.Time     `gorm:"column:last_batch_at" json:"last_batch_at,omitempty"`
+	StartedAt       *time.Time     `gorm:"column:started_at" json:"started_at,omitempty"`
+	FinishedAt      *time.Time     `gorm:"column:finished_at" json:"finished_at,omitempty"`
+	CreatedAt       time.Time      `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+	UpdatedAt       time.Time      `gorm:"autoUpdateTime;column:updated_at" json:"updated_at"`
+}
+
+func (Campaign) TableName() string {
+	return "campaigns"
+}
+
+type CampaignRecipient struct {
+	ID                uint       `gorm:"primaryKey;column:id" json:"id"`
+	CampaignID        uint       `gorm:"index;column:campaign_id" json:"campaign_id"`
+	SessionID         string     `gorm:"column:session_id" json:"session_id"`
+	Phone             string     `gorm:"column:phone" json:"phone"`
+	LeadName          string     `gorm:"column:lead_name" json:"lead_name"`
+	Status            string     `gorm:"column:status" json:"status"`
+	OutboundMessageID *uint      `gorm:"column:outbound_message_id" json:"outbound_message_id,omitempty"`
+	Error             string     `gorm:"column:error" json:"error,omitempty"`
+	SentAt            *time.Time `gorm:"column:sent_at" json:"sent_at,omitempty"`
+	CreatedAt         time.Time  `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+	UpdatedAt         time.Time  `gorm:"autoUpdateTime;column:updated_at" json:"updated_at"`
+}
+
+func (CampaignRecipient) TableName() string {
+	return "campaign_recipients"
+}
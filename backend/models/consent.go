@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// ContactConsent states.
+const (
+	ConsentOptedIn  = "opted_in"
+	ConsentOptedOut = "opted_out"
+)
+
+// ConsentEvent kinds and sources.
+const (
+	ConsentEventOptOut = "opt_out"
+	ConsentEventOptIn  = "opt_in"
+
+	ConsentSourceInbound = "inbound"
+	ConsentSourcePanel   = "panel"
+)
+
+type ContactConsent struct {
+	Phone     string    `gorm:"primaryKey;column:phone" json:"phone"`
+	Status    string    `gorm:"column:status" json:"status"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime;column:updated_at" json:"updated_at"`
+	CreatedAt time.Time `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+}
+
+func (ContactConsent) TableName() string {
+	return "contact_consents"
+}
+
+type ConsentEvent struct {
+	ID        uint      `gorm:"primaryKey;column:id" json:"id"`
+	Phone     string    `gorm:"index;column:phone" json:"phone"`
+	Event     string    `gorm:"column:event" json:"event"`
+	Source    string    `gorm:"column:source" json:"source"`
+	SessionID string    `gorm:"column:session_id" json:"session_id,omitempty"`
+	Message   string    `gorm:"column:message" json:"message,omitempty"`
+	UserID    *int      `gorm:"column:user_id" json:"user_id,omitempty"`
+	HistoryID *uint     `gorm:"column:history_id" json:"history_id,omitempty"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (ConsentEvent) TableName() string {
+	return "consent_events"
+}
+
+// ConsentScanState is the single row recording the last chat history id the
+// consent keyword scanner has read.
+type ConsentScanState struct {
+	ID            int       `gorm:"primaryKey;column:id" json:"id"`
+	LastHistoryID uint      `gorm:"column:last_history_id" json:"last_history_id"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime;column:updated_at" json:"updated_at"`
+}
+
+func (ConsentScanState) TableName() string {
+	return "consent_scan_state"
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Contact is the person behind one or more sessions. Phone holds digits
+// only and is unique.
+type Contact struct {
+	ID                 uint      `gorm:"primaryKey;column:id" json:"id"`
+	Phone              string    `gorm:"column:phone" json:"phone"`
+	Name               string    `gorm:"column:name" json:"name"`
+	Email              string    `gorm:"column:email" json:"email"`
+	CPF                string    `gorm:"column:cpf" json:"cpf"`
+	Convenio           string    `gorm:"column:convenio" json:"convenio"`
+	PreferredSpecialty string    `gorm:"column:preferred_specialty" json:"preferred_specialty"`
+	CreatedAt          time.Time `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+	UpdatedAt          time.Time `gorm:"autoUpdateTime;column:updated_at" json:"updated_at"`
+}
+
+func (Contact) TableName() string {
+	return "contacts"
+}
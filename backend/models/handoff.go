@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// SessionHandoff states.
+const (
+	HandoffQueued   = "queued"
+	HandoffAssigned = "assigned"
+	HandoffReleased = "released"
+)
+
+type SessionHandoff struct {
+	ID             uint       `gorm:"primaryKey;column:id" json:"id"`
+	SessionID      string     `gorm:"column:session_id" json:"session_id"`
+	Status         string     `gorm:"column:status" json:"status"`
+	AssignedUserID *int       `gorm:"column:assigned_user_id" json:"assigned_user_id,omitempty"`
+	AssignedBy     *int       `gorm:"column:assigned_by" json:"assigned_by,omitempty"`
+	QueuedAt       time.Time  `gorm:"column:queued_at" json:"queued_at"`
+	AssignedAt     *time.Time `gorm:"column:assigned_at" json:"assigned_at,omitempty"`
+	ReleasedAt     *time.Time `gorm:"column:released_at" json:"released_at,omitempty"`
+	ReleasedBy     *int       `gorm:"column:released_by" json:"released_by,omitempty"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime;column:updated_at" json:"updated_at"`
+}
+
+func (SessionHandoff) TableName() string {
+	return "session_handoffs"
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// InboundMessage records a provider message id the webhook has stored, so
+// retries of the same message are dropped.
+type InboundMessage struct {
+	ProviderMessageID string    `gorm:"primaryKey;column:provider_message_id" json:"provider_message_id"`
+	Provider          string    `gorm:"column:provider" json:"provider"`
+	SessionID         string    `gorm:"column:session_id" json:"session_id"`
+	ChatHistoryID     *uint     `gorm:"column:chat_history_id" json:"chat_history_id,omitempty"`
+	ReceivedAt        time.Time `gorm:"column:received_at" json:"received_at"`
+	CreatedAt         time.Time `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+}
+
+func (InboundMessage) TableName() string {
+	return "inbound_messages"
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+type MessageDelivery struct {
+	ID                uint       `gorm:"primaryKey;column:id" json:"id"`
+	Provider          string     `gorm:"column:provider" json:"provider"`
+	ProviderMessageID string     `gorm:"uniqueIndex;column:provider_message_id" json:"provider_message_id"`
+	SessionID         string     `gorm:"index;column:session_id" json:"session_id"`
+	ChatHistoryID     *uint      `gorm:"index;column:chat_history_id" json:"chat_history_id"`
+	Recipient         string     `gorm:"column:recipient" json:"recipient"`
+	Status            string     `gorm:"column:status" json:"status"`
+	ErrorCode         string     `gorm:"column:error_code" json:"error_code,omitempty"`
+	ErrorMessage      string     `gorm:"column:error_message" json:"error_message,omitempty"`
+	DeliveredAt       *time.Time `gorm:"column:delivered_at" json:"delivered_at,omitempty"`
+	ReadAt            *time.Time `gorm:"column:read_at" json:"read_at,omitempty"`
+	CreatedAt         time.Time  `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+	UpdatedAt         time.Time  `gorm:"autoUpdateTime;column:updated_at" json:"updated_at"`
+}
+
+func (MessageDelivery) TableName() string {
+	return "message_deliveries"
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// MessageTemplate is a WhatsApp-approved (HSM) template. Body uses
+// {{placeholder}} markers, either positional ({{1}}) or named ({{nome}}).
+type MessageTemplate struct {
+	ID                 uint      `gorm:"primaryKey;column:id" json:"id"`
+	Name               string    `gorm:"column:name" json:"name"`
+	Language           string    `gorm:"column:language;default:pt_BR" json:"language"`
+	Body               string    `gorm:"column:body" json:"body"`
+	ProviderContentSID string    `gorm:"column:provider_content_sid" json:"provider_content_sid"`
+	Category           string    `gorm:"column:category;default:MARKETING" json:"category"`
+	IsActive           bool      `gorm:"column:is_active;default:true" json:"is_active"`
+	CreatedAt          time.Time `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+	UpdatedAt          time.Time `gorm:"autoUpdateTime;column:updated_at" json:"updated_at"`
+}
+
+func (MessageTemplate) TableName() string {
+	return "message_templates"
+}
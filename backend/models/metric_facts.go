@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// SessionMetricFacts holds the figures the dashboard metrics need for one
+// session, computed from its whole chat history. Bot* fields leave out
+// attendant turns. RecaptureIndex is the position of the first recapture
+// message and Reengaged whether the lead wrote after it.
+type SessionMetricFacts struct {
+	SessionID       string     `gorm:"primaryKey;column:session_id" json:"session_id"`
+	MessageCount    int        `gorm:"column:message_count" json:"message_count"`
+	HumanCount      int        `gorm:"column:human_count" json:"human_count"`
+	AttendantCount  int        `gorm:"column:attendant_count" json:"attendant_count"`
+	FirstMessageAt  *time.Time `gorm:"column:first_message_at" json:"first_message_at,omitempty"`
+	LastMessageAt   *time.Time `gorm:"column:last_message_at" json:"last_message_at,omitempty"`
+	MaxFlowState    int        `gorm:"column:max_flow_state" json:"max_flow_state"`
+	BotMaxFlowState int        `gorm:"column:bot_max_flow_state" json:"bot_max_flow_state"`
+	Finalizar       bool       `gorm:"column:finalizar" json:"finalizar"`
+	BotFinalizar    bool       `gorm:"column:bot_finalizar" json:"bot_finalizar"`
+	RecaptureIndex  *int       `gorm:"column:recapture_index" json:"recapture_index,omitempty"`
+	Reengaged       bool       `gorm:"column:reengaged" json:"reengaged"`
+	LastHistoryID   uint       `gorm:"column:last_history_id" json:"last_history_id"`
+	UpdatedAt       time.Time  `gorm:"autoUpdateTime;column:updated_at" json:"updated_at"`
+}
+
+func (SessionMetricFacts) TableName() string {
+	return "session_metric_facts"
+}
+
+// SessionMetricRecapture is the first recapture of a session by a campaign.
+type SessionMetricRecapture struct {
+	SessionID      string `gorm:"primaryKey;column:session_id" json:"session_id"`
+	CampaignID     uint   `gorm:"primaryKey;autoIncrement:false;column:campaign_id" json:"campaign_id"`
+	RecaptureIndex int    `gorm:"column:recapture_index" json:"recapture_index"`
+	Reengaged      bool   `gorm:"column:reengaged" json:"reengaged"`
+}
+
+func (SessionMetricRecapture) TableName() string {
+	return "session_metric_recaptures"
+}
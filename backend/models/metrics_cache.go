@@ -6,10 +6,14 @@ import (
     "gorm.io/datatypes"
 )
 
+// MetricsCache is a metric response computed for the filter in Params.
+// RefreshingUntil is set while a replica recomputes it in the background.
 type MetricsCache struct {
     MetricKey       string         `gorm:"primaryKey;column:metric_key" json:"metric_key"`
+    Params          datatypes.JSON `gorm:"column:params" json:"params"`
     Payload         datatypes.JSON `gorm:"column:payload" json:"payload"`
     LastRefreshedAt time.Time      `gorm:"column:last_refreshed_at" json:"last_refreshed_at"`
+    RefreshingUntil *time.Time     `gorm:"column:refreshing_until" json:"-"`
 }
 
 func (MetricsCache) TableName() string {
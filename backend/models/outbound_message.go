@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Outbox states of an OutboundMessage.
+const (
+	OutboundPending   = "pending"
+	OutboundSending   = "sending"
+	OutboundSent      = "sent"
+	OutboundDead      = "dead"
+	OutboundCancelled = "cancelled"
+)
+
+type OutboundMessage struct {
+	ID                uint           `gorm:"primaryKey;column:id" json:"id"`
+	IdempotencyKey    *string        `gorm:"uniqueIndex;column:idempotency_key" json:"idempotency_key,omitempty"`
+	SessionID         string         `gorm:"index;column:session_id" json:"session_id"`
+	Recipient         string         `gorm:"column:recipient" json:"to"`
+	Body              string         `gorm:"column:body" json:"message"`
+	Vars              datatypes.JSON `gorm:"column:vars" json:"vars,omitempty"`
+	TemplateID        *uint          `gorm:"column:template_id" json:"template_id,omitempty"`
+	TemplateParams    datatypes.JSON `gorm:"column:template_params" json:"template_params,omitempty"`
+	CampaignID        *uint          `gorm:"column:campaign_id" json:"campaign_id,omitempty"`
+	Media             datatypes.JSON `gorm:"column:media" json:"media,omitempty"`
+	SenderUserID      *int           `gorm:"column:sender_user_id" json:"sender_user_id,omitempty"`
+	SenderUsername    string         `gorm:"column:sender_username" json:"sender_username,omitempty"`
+	Status            string         `gorm:"column:status" json:"status"`
+	Attempts          int            `gorm:"column:attempts" json:"attempts"`
+	MaxAttempts       int            `gorm:"column:max_attempts" json:"max_attempts"`
+	NextAttemptAt     time.Time      `gorm:"column:next_attempt_at" json:"next_attempt_at"`
+	SendAt            *time.Time     `gorm:"column:send_at" json:"send_at,omitempty"`
+	LastError         string         `gorm:"column:last_error" json:"last_error,omitempty"`
+	Provider          string         `gorm:"column:provider" json:"provider,omitempty"`
+	ProviderMessageID string         `gorm:"column:provider_message_id" json:"provider_message_id,omitempty"`
+	ChatHistoryID     *uint          `gorm:"column:chat_history_id" json:"chat_history_id,omitempty"`
+	SentAt            *time.Time     `gorm:"column:sent_at" json:"sent_at,omitempty"`
+	CreatedAt         time.Time      `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+	UpdatedAt         time.Time      `gorm:"autoUpdateTime;column:updated_at" json:"updated_at"`
+}
+
+func (OutboundMessage) TableName() string {
+	return "outbound_messages"
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// Pipeline stages, in order. Fechado and perdido are final.
+const (
+	PipelineNovo        = "novo"
+	PipelineQualificado = "qualificado"
+	PipelineProposta    = "proposta"
+	PipelineFechado     = "fechado"
+	PipelinePerdido     = "perdido"
+)
+
+// PipelineStages lists the stages in board order.
+var PipelineStages = []string{
+	PipelineNovo,
+	PipelineQualificado,
+	PipelineProposta,
+	PipelineFechado,
+	PipelinePerdido,
+}
+
+// Who moved a session between stages.
+const (
+	PipelineSourceManual = "manual"
+	PipelineSourceAuto   = "auto"
+)
+
+type SessionPipeline struct {
+	SessionID       string    `gorm:"primaryKey;column:session_id" json:"session_id"`
+	Stage           string    `gorm:"column:stage" json:"stage"`
+	EstimatedValue  *float64  `gorm:"column:estimated_value" json:"estimated_value,omitempty"`
+	LossReason      string    `gorm:"column:loss_reason" json:"loss_reason,omitempty"`
+	StageChangedAt  time.Time `gorm:"column:stage_changed_at" json:"stage_changed_at"`
+	UpdatedBy       *int      `gorm:"column:updated_by" json:"updated_by,omitempty"`
+	UpdatedUsername string    `gorm:"column:updated_username" json:"updated_username,omitempty"`
+	CreatedAt       time.Time `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime;column:updated_at" json:"updated_at"`
+}
+
+func (SessionPipeline) TableName() string {
+	return "session_pipeline"
+}
+
+type PipelineStageChange struct {
+	ID             uint      `gorm:"primaryKey;column:id" json:"id"`
+	SessionID      string    `gorm:"index;column:session_id" json:"session_id"`
+	FromStage      string    `gorm:"column:from_stage" json:"from_stage"`
+	ToStage        string    `gorm:"column:to_stage" json:"to_stage"`
+	EstimatedValue *float64  `gorm:"column:estimated_value" json:"estimated_value,omitempty"`
+	LossReason     string    `gorm:"column:loss_reason" json:"loss_reason,omitempty"`
+	Source         string    `gorm:"column:source" json:"source"`
+	UserID         *int      `gorm:"column:user_id" json:"user_id,omitempty"`
+	Username       string    `gorm:"column:username" json:"username,omitempty"`
+	CreatedAt      time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (PipelineStageChange) TableName() string {
+	return "pipeline_stage_changes"
+}
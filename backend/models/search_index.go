@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// ChatSearchEntry is the searchable text of one n8n_chat_histories row.
+// The tsv column is generated by Postgres and never written from Go.
+type ChatSearchEntry struct {
+	HistoryID uint      `gorm:"primaryKey;autoIncrement:false;column:history_id" json:"history_id"`
+	SessionID string    `gorm:"column:session_id" json:"session_id"`
+	Role      string    `gorm:"column:role" json:"role"`
+	Body      string    `gorm:"column:body" json:"body"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (ChatSearchEntry) TableName() string {
+	return "chat_search_index"
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// SendPolicy holds the clinic's business hours and the per-phone limit on
+// proactive (template) messages. There is a single row with ID 1.
+type SendPolicy struct {
+	ID                   uint      `gorm:"primaryKey;column:id" json:"-"`
+	Timezone             string    `gorm:"column:timezone" json:"timezone"`
+	BusinessStart        string    `gorm:"column:business_start" json:"business_start"`
+	BusinessEnd          string    `gorm:"column:business_end" json:"business_end"`
+	BusinessDays         string    `gorm:"column:business_days" json:"business_days"`
+	MaxProactive         int       `gorm:"column:max_proactive" json:"max_proactive"`
+	ProactiveWindowHours int       `gorm:"column:proactive_window_hours" json:"proactive_window_hours"`
+	UpdatedAt            time.Time `gorm:"autoUpdateTime;column:updated_at" json:"updated_at"`
+}
+
+func (SendPolicy) TableName() string {
+	return "send_policy"
+}
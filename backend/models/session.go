@@ -3,12 +3,16 @@ package models
 import "time"
 
 type SessionPhone struct {
-    SessionID     string    `gorm:"primaryKey;column:session_id" json:"session_id"`
-    Phone         string    `gorm:"column:phone" json:"phone"`
-    AIActive      bool      `gorm:"column:ai_active" json:"ai_active"`
-    CreatedAt     time.Time `gorm:"column:created_at" json:"created_at"`
-    LastMessageAt time.Time `gorm:"column:last_message_at" json:"last_message_at"` 
-    LeadName      string    `gorm:"column:lead_name" json:"lead_name"`
+    SessionID     string     `gorm:"primaryKey;column:session_id" json:"session_id"`
+    Phone         string     `gorm:"column:phone" json:"phone"`
+    AIActive      bool       `gorm:"column:ai_active" json:"ai_active"`
+    CreatedAt     time.Time  `gorm:"column:created_at" json:"created_at"`
+    LastMessageAt time.Time  `gorm:"column:last_message_at" json:"last_message_at"` 
+    LeadName      string     `gorm:"column:lead_name" json:"lead_name"`
+    Version       int        `gorm:"column:version;default:1" json:"version"`
+    FlowState     int        `gorm:"column:flow_state;default:0" json:"flow_state"`
+    FlowStateAt   *time.Time `gorm:"column:flow_state_at" json:"-"`
+    ContactID     *uint      `gorm:"column:contact_id" json:"contact_id,omitempty"`
 }
 
 func (SessionPhone) TableName() string {
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// SessionEvent types.
+const (
+	SessionEventAIActive   = "ai_active"
+	SessionEventTagAdded   = "tag_added"
+	SessionEventTagRemoved = "tag_removed"
+	SessionEventPipeline   = "pipeline_stage"
+)
+
+type SessionEvent struct {
+	ID        uint      `gorm:"primaryKey;column:id" json:"id"`
+	SessionID string    `gorm:"index;column:session_id" json:"session_id"`
+	EventType string    `gorm:"column:event_type" json:"event_type"`
+	OldValue  string    `gorm:"column:old_value" json:"old_value"`
+	NewValue  string    `gorm:"column:new_value" json:"new_value"`
+	Reason    string    `gorm:"column:reason" json:"reason,omitempty"`
+	UserID    *int      `gorm:"column:user_id" json:"user_id,omitempty"`
+	Username  string    `gorm:"column:username" json:"username,omitempty"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (SessionEvent) TableName() string {
+	return "session_events"
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SessionNote struct {
+	ID             uint           `gorm:"primaryKey;column:id" json:"id"`
+	SessionID      string         `gorm:"index;column:session_id" json:"session_id"`
+	Body           string         `gorm:"column:body" json:"body"`
+	AuthorID       *int           `gorm:"column:author_id" json:"author_id,omitempty"`
+	AuthorUsername string         `gorm:"column:author_username" json:"author_username"`
+	CreatedAt      time.Time      `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime;column:updated_at" json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"column:deleted_at" json:"-"`
+}
+
+func (SessionNote) TableName() string {
+	return "session_notes"
+}
+
+// SessionNoteRevision keeps the text a note had before an edit.
+type SessionNoteRevision struct {
+	ID             uint      `gorm:"primaryKey;column:id" json:"id"`
+	NoteID         uint      `gorm:"index;column:note_id" json:"note_id"`
+	Body           string    `gorm:"column:body" json:"body"`
+	EditedBy       *int      `gorm:"column:edited_by" json:"edited_by,omitempty"`
+	EditedUsername string    `gorm:"column:edited_username" json:"edited_username"`
+	EditedAt       time.Time `gorm:"column:edited_at" json:"edited_at"`
+}
+
+func (SessionNoteRevision) TableName() string {
+	return "session_note_revisions"
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// SessionRead marks how far a panel user has read a session: every chat
+// message up to LastReadAt has been seen.
+type SessionRead struct {
+	UserID     int       `gorm:"primaryKey;column:user_id" json:"user_id"`
+	SessionID  string    `gorm:"primaryKey;column:session_id" json:"session_id"`
+	LastReadAt time.Time `gorm:"column:last_read_at" json:"last_read_at"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime;column:updated_at" json:"updated_at"`
+}
+
+func (SessionRead) TableName() string {
+	return "session_reads"
+}
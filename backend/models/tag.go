@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+type Tag struct {
+	ID        uint      `gorm:"primaryKey;column:id" json:"id"`
+	Name      string    `gorm:"column:name" json:"name"`
+	Color     string    `gorm:"column:color" json:"color,omitempty"`
+	CreatedBy *int      `gorm:"column:created_by" json:"created_by,omitempty"`
+	CreatedAt time.Time `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+}
+
+func (Tag) TableName() string {
+	return "tags"
+}
+
+type SessionTag struct {
+	SessionID string    `gorm:"primaryKey;column:session_id" json:"session_id"`
+	TagID     uint      `gorm:"primaryKey;column:tag_id" json:"tag_id"`
+	CreatedBy *int      `gorm:"column:created_by" json:"created_by,omitempty"`
+	CreatedAt time.Time `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+}
+
+func (SessionTag) TableName() string {
+	return "session_tags"
+}
@@ -0,0 +1,157 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"bestdoctors_service/internal/config"
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/internal/session"
+	"bestdoctors_service/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	aiReactivationInterval = time.Minute
+	// aiReactivationBatch caps the sessions handed back per run, so turning
+	// the job on doesn't wake up (and message) every old session at once.
+	aiReactivationBatch = 50
+)
+
+// systemActor marks session events made by background jobs.
+var systemActor = &session.SessionData{Username: "system"}
+
+// aiReactivationConfig is read from the environment:
+// AI_REACTIVATE_AFTER_MINUTES (0 or unset disables the job),
+// AI_REACTIVATE_TEMPLATE and AI_REACTIVATE_TEMPLATE_LANGUAGE (optional
+// message sent to the lead when the AI takes over again).
+type aiReactivationConfig struct {
+	After    time.Duration
+	Template string
+	Language string
+}
+
+func loadAIReactivationConfig() aiReactivationConfig {
+	minutes, _ := strconv.Atoi(config.Env("AI_REACTIVATE_AFTER_MINUTES"))
+	return aiReactivationConfig{
+		After:    time.Duration(minutes) * time.Minute,
+		Template: config.Env("AI_REACTIVATE_TEMPLATE"),
+		Language: config.Env("AI_REACTIVATE_TEMPLATE_LANGUAGE"),
+	}
+}
+
+// StartAIReactivationWorker hands sessions back to the AI once no attendant
+// has touched them for the configured time, until ctx is cancelled.
+func StartAIReactivationWorker(ctx context.Context) {
+	cfg := loadAIReactivationConfig()
+	if cfg.After <= 0 {
+		return
+	}
+	log.Printf("AI re-activation after %s of attendant inactivity", cfg.After)
+
+	ticker := time.NewTicker(aiReactivationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reactivateIdleSessions(cfg)
+		}
+	}
+}
+
+// idleAttendantSessions returns up to aiReactivationBatch sessions with the
+// AI off whose last attendant activity (manual message, AI switch, handoff
+// queue/claim) is older than cutoff, longest idle first. Sessions with no
+// recorded activity (the AI turned off by n8n or before events were kept)
+// fall back to their last message.
+func idleAttendantSessions(cutoff time.Time) []string {
+	var ids []string
+	if err := db.DB.Raw(`
+		SELECT session_id FROM (
+		  SELECT sp.session_id,
+		         COALESCE(GREATEST(
+		           (SELECT MAX(o.created_at) FROM outbound_messages o
+		             WHERE o.session_id = sp.session_id AND o.sender_user_id IS NOT NULL),
+		           (SELECT MAX(e.created_at) FROM session_events e
+		             WHERE e.session_id = sp.session_id AND e.event_type = ?),
+		           (SELECT MAX(GREATEST(h.queued_at, COALESCE(h.assigned_at, h.queued_at))) FROM session_handoffs h
+		             WHERE h.session_id = sp.session_id)
+		         ), sp.last_message_at) AS last_activity
+		  FROM session_phones sp
+		  WHERE sp.ai_active = false
+		) idle
+		WHERE last_activity < ?
+		ORDER BY last_activity
+		LIMIT ?`, models.SessionEventAIActive, cutoff, aiReactivationBatch).
+		Scan(&ids).Error; err != nil {
+		log.Printf("❌ Failed to find idle sessions: %v", err)
+	}
+	return ids
+}
+
+func reactivateIdleSessions(cfg aiReactivationConfig) {
+	now := time.Now().UTC()
+	reason := fmt.Sprintf("no attendant activity for %d minutes", int(cfg.After.Minutes()))
+
+	for _, sid := range idleAttendantSessions(now.Add(-cfg.After)) {
+		var (
+			s       models.SessionPhone
+			changed bool
+		)
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			// The handoff trigger on session_phones releases the handoff.
+			s, changed, err = setAIActive(tx, sid, true, nil, systemActor, reason)
+			return err
+		})
+		if err != nil {
+			log.Printf("❌ Failed to re-activate AI for %s: %v", sid, err)
+			continue
+		}
+		// Another replica got there first.
+		if !changed {
+			continue
+		}
+		if cfg.Template != "" {
+			queueReactivationMessage(cfg, &s)
+		}
+	}
+}
+
+// queueReactivationMessage tells the lead the AI is back. The outbox applies
+// consent and send policy like any other message.
+func queueReactivationMessage(cfg aiReactivationConfig, s *models.SessionPhone) {
+	tpl, err := findTemplate(cfg.Template, cfg.Language)
+	if err != nil {
+		log.Printf("❌ AI re-activation template %q: %v", cfg.Template, err)
+		return
+	}
+	params := recipientParams(nil, s.LeadName)
+	body, _, err := providerTemplate(tpl, params)
+	if err != nil {
+		log.Printf("❌ AI re-activation template %q: %v", cfg.Template, err)
+		return
+	}
+
+	paramsJSON, _ := json.Marshal(params)
+	key := fmt.Sprintf("ai-reactivate-%s-%d", s.SessionID, s.Version)
+	out := models.OutboundMessage{
+		IdempotencyKey: &key,
+		SessionID:      s.SessionID,
+		Recipient:      s.Phone,
+		Body:           body,
+		TemplateID:     &tpl.ID,
+		TemplateParams: paramsJSON,
+	}
+	if _, err := enqueueOutbound(&out); err != nil {
+		log.Printf("❌ Failed to queue AI re-activation message for %s: %v", s.SessionID, err)
+	}
+}
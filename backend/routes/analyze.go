@@ -2,10 +2,13 @@ package routes
 
 import (
     "encoding/json"
+    "errors"
     "net/http"
 
     "bestdoctors_service/internal/db"
     "bestdoctors_service/models"
+
+    "gorm.io/gorm"
 )
 
 type SessionMetrics struct {
@@ -17,43 +20,33 @@ type SessionMetrics struct {
 
 type GlobalMetrics struct {
     TotalSessions                     int64   `json:"total_sessions"`
+    TotalContacts                     int64   `json:"total_contacts"`
     AverageDurationSeconds            float64 `json:"average_duration_seconds"`
     AverageMessagesCount              float64 `json:"average_messages_count"`
     AverageInterMessageDelaySeconds   float64 `json:"average_inter_message_delay_seconds"`
 }
 
+// SessionMetricsHandler handles GET /metrics/session
+// With session_id it returns that session's metrics, otherwise the averages
+// over the filtered sessions. Both read session_metric_facts.
 func SessionMetricsHandler(w http.ResponseWriter, r *http.Request) {
     sessionID := r.URL.Query().Get("session_id")
 
     if sessionID == "" {
-        var ids []string
-        if err := db.DB.
-            Table(models.SessionPhone{}.TableName()).
-            Pluck("session_id", &ids).Error; err != nil {
+        f := metricsFilterFromQuery(r)
+        var global GlobalMetrics
+        if err := f.factsQuery(db.DB, db.DB.Table(models.SessionPhone{}.TableName())).
+            Select(`COUNT(*) AS total_sessions,
+                COUNT(DISTINCT COALESCE('c' || sp.contact_id, 's' || sp.session_id)) AS total_contacts,
+                COALESCE(AVG(` + factsDurationSQL + `), 0) AS average_duration_seconds,
+                COALESCE(AVG(COALESCE(mf.message_count, 0)), 0) AS average_messages_count,
+                COALESCE(AVG(CASE WHEN mf.message_count > 1
+                    THEN ` + factsDurationSQL + ` / (mf.message_count - 1) ELSE 0 END), 0) AS average_inter_message_delay_seconds`).
+            Scan(&global).Error; err != nil {
             http.Error(w, err.Error(), http.StatusInternalServerError)
             return
         }
 
-        total := int64(len(ids))
-        var sumDur, sumMsgs, sumAvgDelays float64
-
-        for _, sid := range ids {
-            m, err := computeMetricsForSession(sid)
-            if err != nil {
-                continue
-            }
-            sumDur += m.DurationSeconds
-            sumMsgs += float64(m.MessagesCount)
-            sumAvgDelays += m.AverageInterMessageDelaySeconds
-        }
-
-        global := GlobalMetrics{TotalSessions: total}
-        if total > 0 {
-            global.AverageDurationSeconds = sumDur / float64(total)
-            global.AverageMessagesCount = sumMsgs / float64(total)
-            global.AverageInterMessageDelaySeconds = sumAvgDelays / float64(total)
-        }
-
         w.Header().Set("Content-Type", "application/json")
         json.NewEncoder(w).Encode(global)
         return
@@ -68,32 +61,42 @@ func SessionMetricsHandler(w http.ResponseWriter, r *http.Request) {
     json.NewEncoder(w).Encode(m)
 }
 
+// factsDurationSQL is the seconds between a session's first and last
+// message; history is sorted, so the gaps between messages add up to it.
+const factsDurationSQL = "COALESCE(EXTRACT(EPOCH FROM mf.last_message_at - mf.first_message_at), 0)"
+
+// computeMetricsForSession reads the session's facts row. When the worker
+// has not caught up with the session yet (no row, or messages newer than
+// last_history_id) the facts are computed from the history instead.
 func computeMetricsForSession(sid string) (SessionMetrics, error) {
-    var history []models.ChatHistory
-    if err := db.DB.
-        Where("session_id = ?", sid).
-        Order("created_at ASC").
-        Find(&history).Error; err != nil {
-        return SessionMetrics{}, err
+    m := SessionMetrics{SessionID: sid}
+    var facts models.SessionMetricFacts
+    err := db.DB.Where("session_id = ?", sid).First(&facts).Error
+    if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+        return m, err
     }
 
-    count := len(history)
-    m := SessionMetrics{SessionID: sid, MessagesCount: count}
-
-    if count > 0 {
-        start := history[0].CreatedAt
-        end := history[count-1].CreatedAt
-        m.DurationSeconds = end.Sub(start).Seconds()
+    var lastID uint
+    if err := db.DB.Model(&models.ChatHistory{}).
+        Where("session_id = ?", sid).
+        Select("COALESCE(MAX(id), 0)").
+        Scan(&lastID).Error; err != nil {
+        return m, err
     }
-    if count > 1 {
-        var sumDelays float64
-        for i := 1; i < count; i++ {
-            delta := history[i].CreatedAt.Sub(history[i-1].CreatedAt).Seconds()
-            if delta > 0 {
-                sumDelays += delta
-            }
+    if lastID > facts.LastHistoryID {
+        var history []models.ChatHistory
+        if err := db.DB.Where("session_id = ?", sid).Order("created_at ASC, id ASC").Find(&history).Error; err != nil {
+            return m, err
         }
-        m.AverageInterMessageDelaySeconds = sumDelays / float64(count-1)
+        facts, _ = computeSessionFacts(sid, history)
+    }
+
+    m.MessagesCount = facts.MessageCount
+    if facts.FirstMessageAt != nil && facts.LastMessageAt != nil {
+        m.DurationSeconds = facts.LastMessageAt.Sub(*facts.FirstMessageAt).Seconds()
+    }
+    if facts.MessageCount > 1 {
+        m.AverageInterMessageDelaySeconds = m.DurationSeconds / float64(facts.MessageCount-1)
     }
     return m, nil
 }
@@ -0,0 +1,513 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/internal/messaging"
+	"bestdoctors_service/internal/phonenum"
+	"bestdoctors_service/internal/session"
+	"bestdoctors_service/middleware"
+	"bestdoctors_service/models"
+
+	"gorm.io/gorm"
+)
+
+// recapturePrefix marks the human turn that starts a recapture; the
+// reengagement metrics look for it.
+const recapturePrefix = "Recapture - "
+
+const (
+	campaignPollInterval = 10 * time.Second
+	defaultCampaignRate  = 20
+	maxCampaignRate      = 600
+)
+
+// CampaignFilters selects the sessions a campaign targets. From/To apply to
+// last_message_at like getSessionsWithRange; FlowStates keeps sessions whose
+// deepest flow state (see detectFlowState) is listed; Abandoned keeps sessions
+// whose last message did not set finalizar; Tags keeps sessions with any of
+// the tags.
+type CampaignFilters struct {
+	From       *time.Time `json:"from,omitempty"`
+	To         *time.Time `json:"to,omitempty"`
+	FlowStates []int      `json:"flow_states,omitempty"`
+	Abandoned  *bool      `json:"abandoned,omitempty"`
+	AIActive   *bool      `json:"ai_active,omitempty"`
+	Tags       []string   `json:"tags,omitempty"`
+}
+
+type CreateCampaignRequest struct {
+	Name          string            `json:"name"`
+	Template      string            `json:"template"`
+	Language      string            `json:"language"`
+	Params        map[string]string `json:"params"`
+	Filters       CampaignFilters   `json:"filters"`
+	ScheduledAt   *time.Time        `json:"scheduled_at"`
+	RatePerMinute int               `json:"rate_per_minute"`
+}
+
+type CampaignDetail struct {
+	Campaign     models.Campaign      `json:"campaign"`
+	Recipients   map[string]int64     `json:"recipients"`
+	Reengagement ReengagementResponse `json:"reengagement"`
+}
+
+func campaignMarkerPrefix(id uint) string {
+	return fmt.Sprintf("%sCampaign #%d:", recapturePrefix, id)
+}
+
+// CampaignsHandler handles GET/POST /campaigns
+func CampaignsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		var campaigns []models.Campaign
+		db.DB.Order("created_at desc").Find(&campaigns)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(campaigns)
+	case http.MethodPost:
+		createCampaign(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// CampaignHandler handles /campaigns/:id, /campaigns/:id/recipients and
+// /campaigns/:id/cancel
+func CampaignHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/bestdoctors/campaigns/"), "/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid campaign id", http.StatusBadRequest)
+		return
+	}
+
+	var c models.Campaign
+	if err := db.DB.First(&c, id).Error; err != nil {
+		http.Error(w, "campaign not found", http.StatusNotFound)
+		return
+	}
+
+	action := ""
+	if len(parts) > 1 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		getCampaign(w, &c)
+	case action == "recipients" && r.Method == http.MethodGet:
+		var recipients []models.CampaignRecipient
+		tx := db.DB.Where("campaign_id = ?", c.ID)
+		if st := r.URL.Query().Get("status"); st != "" {
+			tx = tx.Where("status = ?", st)
+		}
+		tx.Order("id").Find(&recipients)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(recipients)
+	case action == "cancel" && r.Method == http.MethodPost:
+		cancelCampaign(w, &c)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func createCampaign(w http.ResponseWriter, r *http.Request) {
+	var req CreateCampaignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || req.Template == "" {
+		http.Error(w, "name and template are required", http.StatusBadRequest)
+		return
+	}
+	if req.RatePerMinute == 0 {
+		req.RatePerMinute = defaultCampaignRate
+	}
+	if req.RatePerMinute < 1 || req.RatePerMinute > maxCampaignRate {
+		http.Error(w, fmt.Sprintf("rate_per_minute must be between 1 and %d", maxCampaignRate), http.StatusBadRequest)
+		return
+	}
+
+	tpl, err := findTemplate(req.Template, req.Language)
+	if err != nil {
+		if isNotFound(err) {
+			http.Error(w, "template not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to load template", http.StatusInternalServerError)
+		return
+	}
+	if _, _, err := messaging.RenderTemplate(tpl.Body, recipientParams(req.Params, "lead")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sessions := selectCampaignSessions(req.Filters)
+
+	scheduledAt := time.Now().UTC()
+	if req.ScheduledAt != nil {
+		scheduledAt = req.ScheduledAt.UTC()
+	}
+	paramsJSON, _ := json.Marshal(req.Params)
+	filtersJSON, _ := json.Marshal(req.Filters)
+	c := models.Campaign{
+		Name:           req.Name,
+		TemplateID:     tpl.ID,
+		TemplateParams: paramsJSON,
+		Filters:        filtersJSON,
+		Status:         models.CampaignScheduled,
+		ScheduledAt:    scheduledAt,
+		RatePerMinute:  req.RatePerMinute,
+	}
+	if sd, ok := r.Context().Value(middleware.SessionDataKey).(*session.SessionData); ok && sd.UserID > 0 {
+		c.CreatedBy = &sd.UserID
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&c).Error; err != nil {
+			return err
+		}
+		recipients := make([]models.CampaignRecipient, 0, len(sessions))
+		for _, s := range sessions {
+			if strings.TrimSpace(s.Phone) == "" {
+				continue
+			}
+			// Unnormalizable numbers are kept as is; the outbox rejects
+			// them and the recipient is marked failed.
+			to, err := phonenum.Normalize(s.Phone)
+			if err != nil {
+				to = s.Phone
+			}
+			recipients = append(recipients, models.CampaignRecipient{
+				CampaignID: c.ID,
+				SessionID:  s.SessionID,
+				Phone:      to,
+				LeadName:   s.LeadName,
+				Status:     models.RecipientPending,
+			})
+		}
+		if len(recipients) > 0 {
+			if err := tx.CreateInBatches(&recipients, 500).Error; err != nil {
+				return err
+			}
+		}
+		c.TotalRecipients = len(recipients)
+		return tx.Model(&c).Update("total_recipients", c.TotalRecipients).Error
+	})
+	if err != nil {
+		log.Printf("❌ Failed to create campaign: %v", err)
+		http.Error(w, "failed to create campaign", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+func getCampaign(w http.ResponseWriter, c *models.Campaign) {
+	type statusCount struct {
+		Status string
+		Count  int64
+	}
+	var counts []statusCount
+	db.DB.Model(&models.CampaignRecipient{}).
+		Select("status, COUNT(*) AS count").
+		Where("campaign_id = ?", c.ID).
+		Group("status").
+		Scan(&counts)
+
+	detail := CampaignDetail{Campaign: *c, Recipients: map[string]int64{}}
+	for _, sc := range counts {
+		detail.Recipients[sc.Status] = sc.Count
+	}
+	detail.Reengagement, _ = CalculateReengagementMetricsFiltered(db.SupabaseDB, false, MetricsFilter{}, c.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+func cancelCampaign(w http.ResponseWriter, c *models.Campaign) {
+	if c.Status == models.CampaignCompleted || c.Status == models.CampaignCancelled {
+		http.Error(w, "campaign already finished", http.StatusConflict)
+		return
+	}
+
+	now := time.Now().UTC()
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(c).Updates(map[string]interface{}{
+			"status":      models.CampaignCancelled,
+			"finished_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.CampaignRecipient{}).
+			Where("campaign_id = ? AND status = ?", c.ID, models.RecipientPending).
+			Update("status", models.RecipientSkipped).Error; err != nil {
+			return err
+		}
+		// Cancelled, not dead: these were never attempted and must not count
+		// as delivery failures.
+		if err := tx.Model(&models.OutboundMessage{}).
+			Where("campaign_id = ? AND status = ?", c.ID, models.OutboundPending).
+			Updates(map[string]interface{}{
+				"status":     models.OutboundCancelled,
+				"last_error": "campaign cancelled",
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.CampaignRecipient{}).
+			Where("campaign_id = ? AND status = ? AND outbound_message_id IN (?)", c.ID, models.RecipientQueued,
+				tx.Model(&models.OutboundMessage{}).Select("id").
+					Where("campaign_id = ? AND status = ?", c.ID, models.OutboundCancelled)).
+			Update("status", models.RecipientSkipped).Error
+	})
+	if err != nil {
+		http.Error(w, "failed to cancel campaign", http.StatusInternalServerError)
+		return
+	}
+
+	c.Status = models.CampaignCancelled
+	c.FinishedAt = &now
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// recipientParams adds the per-recipient lead_name to the campaign params.
+func recipientParams(params map[string]string, leadName string) map[string]string {
+	out := make(map[string]string, len(params)+1)
+	for k, v := range params {
+		out[k] = v
+	}
+	if _, ok := out["lead_name"]; !ok {
+		out["lead_name"] = leadName
+	}
+	return out
+}
+
+// sessionFlowSummary returns the deepest flow state reached by the AI and
+// whether the last message set finalizar, the same rules the flow-depth and
+// abandonment metrics use.
+func sessionFlowSummary(history []models.ChatHistory) (maxState int, finalizar bool) {
+	for i, entry := range history {
+		var rm flowRawMessage
+		if err := json.Unmarshal([]byte(entry.Message), &rm); err != nil {
+			continue
+		}
+		var cp flowContentPayload
+		if err := json.Unmarshal([]byte(rm.Content), &cp); err != nil {
+			continue
+		}
+		if rm.Type == "ai" {
+			if st := detectFlowState(cp); st > maxState {
+				maxState = st
+			}
+		}
+		if i == len(history)-1 {
+			finalizar = cp.Output.Vars.Finalizar
+		}
+	}
+	return maxState, finalizar
+}
+
+// selectCampaignSessions applies CampaignFilters to session_phones.
+func selectCampaignSessions(f CampaignFilters) []models.SessionPhone {
+	sessions := getSessionsFiltered(MetricsFilter{From: f.From, To: f.To, Tags: normalizeTagNames(f.Tags)})
+
+	needsHistory := len(f.FlowStates) > 0 || f.Abandoned != nil
+	allowedStates := make(map[int]bool, len(f.FlowStates))
+	for _, st := range f.FlowStates {
+		allowedStates[st] = true
+	}
+
+	out := make([]models.SessionPhone, 0, len(sessions))
+	for _, s := range sessions {
+		if f.AIActive != nil && s.AIActive != *f.AIActive {
+			continue
+		}
+		if needsHistory {
+			var history []models.ChatHistory
+			db.DB.Where("session_id = ?", s.SessionID).Order("created_at ASC").Find(&history)
+			maxState, finalizar := sessionFlowSummary(history)
+			if len(allowedStates) > 0 && !allowedStates[maxState] {
+				continue
+			}
+			if f.Abandoned != nil && *f.Abandoned == finalizar {
+				continue
+			}
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// StartCampaignWorker starts due campaigns and feeds their recipients into
+// the outbox at each campaign's rate until ctx is cancelled.
+func StartCampaignWorker(ctx context.Context) {
+	ticker := time.NewTicker(campaignPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			processCampaigns()
+		}
+	}
+}
+
+func processCampaigns() {
+	now := time.Now().UTC()
+	db.DB.Model(&models.Campaign{}).
+		Where("status = ? AND scheduled_at <= ?", models.CampaignScheduled, now).
+		Updates(map[string]interface{}{"status": models.CampaignRunning, "started_at": now})
+
+	var running []models.Campaign
+	if err := db.DB.Where("status = ?", models.CampaignRunning).Find(&running).Error; err != nil {
+		log.Printf("❌ Failed to load campaigns: %v", err)
+		return
+	}
+	for i := range running {
+		if err := feedCampaign(&running[i], now); err != nil {
+			log.Printf("❌ Campaign %d: %v", running[i].ID, err)
+		}
+	}
+}
+
+// feedCampaign queues as many recipients as the rate allows since the last
+// batch, and completes the campaign once nothing is left in flight. Time
+// is counted from the start until the first batch, so campaigns slower
+// than one message per poll interval still build up an allowance.
+func feedCampaign(c *models.Campaign, now time.Time) error {
+	last := now.Add(-campaignPollInterval)
+	switch {
+	case c.LastBatchAt != nil:
+		last = *c.LastBatchAt
+	case c.StartedAt != nil:
+		last = *c.StartedAt
+	}
+	allowance := int(math.Floor(float64(c.RatePerMinute) * now.Sub(last).Minutes()))
+	if allowance > c.RatePerMinute {
+		allowance = c.RatePerMinute
+	}
+
+	if allowance > 0 {
+		var tpl models.MessageTemplate
+		if err := db.DB.First(&tpl, c.TemplateID).Error; err != nil {
+			return err
+		}
+		var params map[string]string
+		_ = json.Unmarshal(c.TemplateParams, &params)
+
+		var batch []models.CampaignRecipient
+		db.DB.Where("campaign_id = ? AND status = ?", c.ID, models.RecipientPending).
+			Order("id").Limit(allowance).Find(&batch)
+
+		for i := range batch {
+			queueCampaignRecipient(c, &tpl, params, &batch[i])
+		}
+		if len(batch) > 0 {
+			db.DB.Model(c).Update("last_batch_at", now)
+		}
+	}
+
+	var inFlight int64
+	db.DB.Model(&models.CampaignRecipient{}).
+		Where("campaign_id = ? AND status IN ?", c.ID, []string{models.RecipientPending, models.RecipientQueued}).
+		Count(&inFlight)
+	if inFlight == 0 {
+		return db.DB.Model(c).Updates(map[string]interface{}{
+			"status":      models.CampaignCompleted,
+			"finished_at": now,
+		}).Error
+	}
+	return nil
+}
+
+func queueCampaignRecipient(c *models.Campaign, tpl *models.MessageTemplate, params map[string]string, rcpt *models.CampaignRecipient) {
+	optedOut, err := isOptedOut(rcpt.Phone)
+	if err != nil {
+		// Left pending; the next batch tries again.
+		log.Printf("❌ Failed to check consent for campaign %d recipient %d: %v", c.ID, rcpt.ID, err)
+		return
+	}
+	if optedOut {
+		db.DB.Model(rcpt).Updates(map[string]interface{}{"status": models.RecipientSkipped, "error": errOptedOut.Error()})
+		return
+	}
+
+	p := recipientParams(params, rcpt.LeadName)
+	body, _, err := messaging.RenderTemplate(tpl.Body, p)
+	if err != nil {
+		db.DB.Model(rcpt).Updates(map[string]interface{}{"status": models.RecipientSkipped, "error": err.Error()})
+		return
+	}
+
+	paramsJSON, _ := json.Marshal(p)
+	key := fmt.Sprintf("campaign-%d-%s", c.ID, rcpt.SessionID)
+	out := models.OutboundMessage{
+		IdempotencyKey: &key,
+		SessionID:      rcpt.SessionID,
+		Recipient:      rcpt.Phone,
+		Body:           body,
+		TemplateID:     &tpl.ID,
+		TemplateParams: paramsJSON,
+		CampaignID:     &c.ID,
+	}
+	if _, err := enqueueOutbound(&out); err != nil {
+		db.DB.Model(rcpt).Updates(map[string]interface{}{"status": models.RecipientFailed, "error": err.Error()})
+		return
+	}
+	db.DB.Model(rcpt).Updates(map[string]interface{}{
+		"status":              models.RecipientQueued,
+		"outbound_message_id": out.ID,
+	})
+}
+
+// recordCampaignSend writes the recapture marker right before the template
+// message, so CalculateReengagementMetricsFiltered attributes replies to the
+// campaign, and marks the recipient as sent.
+func recordCampaignSend(tx *gorm.DB, m *models.OutboundMessage, at time.Time) error {
+	var c models.Campaign
+	if err := tx.First(&c, *m.CampaignID).Error; err != nil {
+		return err
+	}
+
+	marker := map[string]interface{}{
+		"type":              "human",
+		"content":           fmt.Sprintf("%s %s", campaignMarkerPrefix(c.ID), c.Name),
+		"additional_kwargs": map[string]interface{}{"campaign_id": c.ID},
+		"response_metadata": map[string]interface{}{},
+	}
+	jsonMarker, _ := json.Marshal(marker)
+	if err := tx.Create(&models.ChatHistory{
+		SessionID: m.SessionID,
+		Message:   string(jsonMarker),
+		CreatedAt: at.Add(-time.Millisecond),
+	}).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.CampaignRecipient{}).
+		Where("campaign_id = ? AND outbound_message_id = ?", c.ID, m.ID).
+		Updates(map[string]interface{}{"status": models.RecipientSent, "sent_at": at}).Error
+}
+
+// recordCampaignFailure marks the recipient of a dead-lettered message.
+func recordCampaignFailure(m *models.OutboundMessage) {
+	db.DB.Model(&models.CampaignRecipient{}).
+		Where("campaign_id = ? AND outbound_message_id = ?", *m.CampaignID, m.ID).
+		Updates(map[string]interface{}{"status": models.RecipientFailed, "error": m.LastError})
+}
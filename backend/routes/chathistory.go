@@ -2,7 +2,9 @@ package routes
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
 
@@ -12,11 +14,22 @@ import (
 
 
 type HistoryResponse struct {
-	ID         uint        `json:"id"`
-	SessionID  string      `json:"session_id"`
-	CreatedAt  time.Time   `json:"created_at"`
-	Message    interface{} `json:"message"`               
-	MessageRaw *string     `json:"message_raw,omitempty"` 
+	ID         uint          `json:"id"`
+	SessionID  string        `json:"session_id"`
+	CreatedAt  time.Time     `json:"created_at"`
+	Message    interface{}   `json:"message"`
+	MessageRaw *string       `json:"message_raw,omitempty"`
+	Delivery   *DeliveryInfo `json:"delivery,omitempty"`
+	// Sender is set for messages typed by an attendant in the panel.
+	Sender *MessageSender `json:"sender,omitempty"`
+	// Event items (?events=true) carry a session audit entry instead of a message.
+	Event *models.SessionEvent `json:"event,omitempty"`
+	// Note items (?notes=true) carry an internal attendant note.
+	Note *models.SessionNote `json:"note,omitempty"`
+	// Pending items are scheduled messages that have not been sent yet;
+	// CreatedAt is when they are due.
+	Pending     bool  `json:"pending,omitempty"`
+	ScheduledID *uint `json:"scheduled_id,omitempty"`
 }
 
 func parseMessage(raw string) interface{} {
@@ -32,9 +45,59 @@ func parseMessage(raw string) interface{} {
 			}
 		}
 	}
+	if a := findAttachment(top); a != nil {
+		top["attachment"] = a
+	}
 	return top
 }
 
+// historySender reads the "sender" key panel sends add to the message JSON.
+func historySender(raw string) *MessageSender {
+	var m struct {
+		Sender *MessageSender `json:"sender"`
+	}
+	if err := json.Unmarshal([]byte(raw), &m); err != nil {
+		return nil
+	}
+	return m.Sender
+}
+
+// isAttendantTurn reports whether a ChatHistory row was typed by a panel
+// user rather than produced by the bot or the lead.
+func isAttendantTurn(h models.ChatHistory) bool {
+	return historySender(h.Message) != nil
+}
+
+// withoutAttendantTurns drops attendant messages so bot metrics only see
+// the bot and the lead.
+func withoutAttendantTurns(history []models.ChatHistory) []models.ChatHistory {
+	out := history[:0:0]
+	for _, h := range history {
+		if !isAttendantTurn(h) {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// findAttachment looks for media metadata in content.output.attachment
+// (panel sends) or additional_kwargs.attachment (inbound webhooks).
+func findAttachment(top map[string]interface{}) interface{} {
+	if content, ok := top["content"].(map[string]interface{}); ok {
+		if output, ok := content["output"].(map[string]interface{}); ok {
+			if a, ok := output["attachment"]; ok && a != nil {
+				return a
+			}
+		}
+	}
+	if kwargs, ok := top["additional_kwargs"].(map[string]interface{}); ok {
+		if a, ok := kwargs["attachment"]; ok && a != nil {
+			return a
+		}
+	}
+	return nil
+}
+
 func ChatHistoryHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -55,9 +118,11 @@ func getHistoryNormalized(w http.ResponseWriter, r *http.Request) {
 	}
 
 	tx := db.DB.Where("session_id = ?", sid)
+	var sinceTime *time.Time
 	if since := q.Get("since"); since != "" {
 		if t, err := time.Parse(time.RFC3339, since); err == nil {
 			tx = tx.Where("created_at > ?", t)
+			sinceTime = &t
 		}
 	}
 
@@ -92,9 +157,50 @@ func getHistoryNormalized(w http.ResponseWriter, r *http.Request) {
 	var rows []models.ChatHistory
 	tx.Order("created_at asc").Find(&rows)
 
+	// Opening the chat reads it, up to the newest message returned.
+	if len(rows) > 0 {
+		if err := markSessionRead(db.DB, sessionUserID(r), sid, rows[len(rows)-1].ID); err != nil {
+			log.Printf("❌ Failed to update read marker for %s: %v", sid, err)
+		}
+	}
+
 	includeRaw := q.Get("raw") == "1" || q.Get("raw") == "true"
 	pretty := q.Get("pretty") == "1" || q.Get("pretty") == "true"
 
+	out := historyItems(rows, includeRaw)
+	if fromVal == 0 && toVal == 0 && (q.Get("events") == "true" || q.Get("notes") == "true") {
+		if q.Get("events") == "true" {
+			out = append(out, sessionEventHistory(sid, sinceTime)...)
+		}
+		if q.Get("notes") == "true" {
+			out = append(out, sessionNoteHistory(sid, sinceTime)...)
+		}
+		sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	}
+	if fromVal == 0 && toVal == 0 && q.Get("scheduled") != "0" {
+		out = append(out, pendingScheduledHistory(sid)...)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if pretty {
+		blob, _ := json.MarshalIndent(out, "", "  ")
+		_, _ = w.Write(blob)
+		return
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(out)
+}
+
+// historyItems turns chat history rows into response items with their
+// delivery status and sender.
+func historyItems(rows []models.ChatHistory, includeRaw bool) []HistoryResponse {
+	ids := make([]uint, 0, len(rows))
+	for _, h := range rows {
+		ids = append(ids, h.ID)
+	}
+	deliveries := deliveriesByHistoryID(ids)
+
 	out := make([]HistoryResponse, 0, len(rows))
 	for _, h := range rows {
 		item := HistoryResponse{
@@ -102,6 +208,8 @@ func getHistoryNormalized(w http.ResponseWriter, r *http.Request) {
 			SessionID: h.SessionID,
 			CreatedAt: h.CreatedAt,
 			Message:   parseMessage(h.Message),
+			Delivery:  deliveries[h.ID],
+			Sender:    historySender(h.Message),
 		}
 		if includeRaw {
 			raw := h.Message
@@ -109,16 +217,7 @@ func getHistoryNormalized(w http.ResponseWriter, r *http.Request) {
 		}
 		out = append(out, item)
 	}
-
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	if pretty {
-		blob, _ := json.MarshalIndent(out, "", "  ")
-		_, _ = w.Write(blob)
-		return
-	}
-	enc := json.NewEncoder(w)
-	enc.SetEscapeHTML(false)
-	_ = enc.Encode(out)
+	return out
 }
 
 func postHistory(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,172 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/internal/phonenum"
+	"bestdoctors_service/internal/session"
+	"bestdoctors_service/middleware"
+	"bestdoctors_service/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errOptedOut = errors.New("recipient has opted out of WhatsApp messages")
+
+type ConsentRequest struct {
+	Phone     string `json:"phone"`
+	Status    string `json:"status"`
+	SessionID string `json:"session_id,omitempty"`
+	Note      string `json:"note,omitempty"`
+}
+
+type ConsentResponse struct {
+	Phone     string                `json:"phone"`
+	Status    string                `json:"status"`
+	UpdatedAt *time.Time            `json:"updated_at,omitempty"`
+	Events    []models.ConsentEvent `json:"events"`
+}
+
+// isOptedOut reports whether phone asked not to be messaged. A phone
+// without a consent row is not opted out; any other lookup error is
+// returned, so callers hold the message instead of sending blind.
+// Rows written before phones were normalized may be keyed by other digit
+// forms of the number (e.g. without the 9th digit); the latest one wins.
+func isOptedOut(phone string) (bool, error) {
+	var c models.ContactConsent
+	err := db.DB.Where("phone IN ?", consentKeys(phone)).
+		Order("updated_at desc").
+		First(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return c.Status == models.ConsentOptedOut, nil
+}
+
+// consentKeys lists the contact_consents keys phone may be stored under:
+// its Key and the digit forms of its Variants.
+func consentKeys(phone string) []string {
+	keys := []string{phonenum.Key(phone)}
+	for _, v := range phonenum.Variants(phone) {
+		if d := digitsOnly(v); d != "" && !slices.Contains(keys, d) {
+			keys = append(keys, d)
+		}
+	}
+	return keys
+}
+
+// setConsent records ev and moves the phone's current status accordingly.
+// An event tied to a chat message (HistoryID) is applied only once.
+func setConsent(tx *gorm.DB, ev models.ConsentEvent) error {
+	status := models.ConsentOptedIn
+	if ev.Event == models.ConsentEventOptOut {
+		status = models.ConsentOptedOut
+	}
+	ev.Phone = phonenum.Key(ev.Phone)
+	if ev.CreatedAt.IsZero() {
+		ev.CreatedAt = time.Now().UTC()
+	}
+
+	if ev.HistoryID != nil {
+		res := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "history_id"}},
+			DoNothing: true,
+		}).Create(&ev)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+	} else if err := tx.Create(&ev).Error; err != nil {
+		return err
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "phone"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"status": status, "updated_at": ev.CreatedAt}),
+	}).Create(&models.ContactConsent{Phone: ev.Phone, Status: status}).Error
+}
+
+// ConsentHandler handles /consent
+// GET ?phone= returns the current status and its event history; POST records
+// a manual opt-out or opt-in made from the panel.
+func ConsentHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getConsent(w, r)
+	case http.MethodPost:
+		updateConsent(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func getConsent(w http.ResponseWriter, r *http.Request) {
+	phone := phonenum.Key(r.URL.Query().Get("phone"))
+	if phone == "" {
+		http.Error(w, "phone is required", http.StatusBadRequest)
+		return
+	}
+	writeConsent(w, phone)
+}
+
+func updateConsent(w http.ResponseWriter, r *http.Request) {
+	var req ConsentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.Phone == "" {
+		http.Error(w, "phone is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := phonenum.Normalize(req.Phone); err != nil {
+		http.Error(w, "invalid phone: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	phone := phonenum.Key(req.Phone)
+
+	ev := models.ConsentEvent{
+		Phone:     phone,
+		Source:    models.ConsentSourcePanel,
+		SessionID: req.SessionID,
+		Message:   req.Note,
+	}
+	switch req.Status {
+	case models.ConsentOptedOut:
+		ev.Event = models.ConsentEventOptOut
+	case models.ConsentOptedIn:
+		ev.Event = models.ConsentEventOptIn
+	default:
+		http.Error(w, "status must be opted_in or opted_out", http.StatusBadRequest)
+		return
+	}
+	if sd, ok := r.Context().Value(middleware.SessionDataKey).(*session.SessionData); ok && sd.UserID > 0 {
+		ev.UserID = &sd.UserID
+	}
+
+	if err := db.DB.Transaction(func(tx *gorm.DB) error { return setConsent(tx, ev) }); err != nil {
+		http.Error(w, "failed to update consent", http.StatusInternalServerError)
+		return
+	}
+	writeConsent(w, phone)
+}
+
+func writeConsent(w http.ResponseWriter, phone string) {
+	resp := ConsentResponse{Phone: phone, Status: models.ConsentOptedIn, Events: []models.ConsentEvent{}}
+	var c models.ContactConsent
+	if err := db.DB.Where("phone = ?", phone).First(&c).Error; err == nil {
+		resp.Status = c.Status
+		resp.UpdatedAt = &c.UpdatedAt
+	}
+	db.DB.Where("phone = ?", phone).Order("created_at desc").Find(&resp.Events)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,134 @@
+package routes
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/internal/messaging"
+	"bestdoctors_service/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	consentScanInterval = 15 * time.Second
+	consentScanBatch    = 500
+	consentScanLookback = 200
+	// Longer messages can't be a consent keyword, which is matched against
+	// the whole message; this only keeps the long ones out of the query.
+	consentKeywordMaxLen = 64
+)
+
+// consentScanRow is a lead message read by the consent keyword scanner.
+type consentScanRow struct {
+	ID        uint
+	SessionID string
+	Content   string
+	Phone     string
+	CreatedAt time.Time
+}
+
+// StartConsentKeywordScanner applies "PARAR"/"VOLTAR" and the other
+// consent keywords found in lead messages from any writer (n8n included)
+// until ctx is cancelled. The webhook applies its own messages right away;
+// the event's history_id keeps a message from being applied twice.
+func StartConsentKeywordScanner(ctx context.Context) {
+	ticker := time.NewTicker(consentScanInterval)
+	defer ticker.Stop()
+
+	for {
+		for scanConsentKeywords() == consentScanBatch {
+			if ctx.Err() != nil {
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scanConsentKeywords reads up to consentScanBatch short lead messages
+// past the stored cursor, applies the keywords among them and returns how
+// many it read. As in the search indexer, ids a little below the cursor
+// are read again, since ids may commit out of order.
+func scanConsentKeywords() int {
+	var state models.ConsentScanState
+	if err := db.DB.First(&state, 1).Error; err != nil {
+		log.Printf("❌ Failed to load consent scan state: %v", err)
+		return 0
+	}
+	floor := uint(0)
+	if state.LastHistoryID > consentScanLookback {
+		floor = state.LastHistoryID - consentScanLookback
+	}
+
+	var rows []consentScanRow
+	if err := db.DB.Table("n8n_chat_histories h").
+		Select("h.id, h.session_id, h.message::jsonb ->> 'content' AS content, COALESCE(sp.phone, '') AS phone, h.created_at").
+		Joins("LEFT JOIN session_phones sp ON sp.session_id = h.session_id").
+		Where("h.id > ? AND (h.message::jsonb ->> 'type') = 'human'", floor).
+		Where("length(h.message::jsonb ->> 'content') <= ?", consentKeywordMaxLen).
+		Order("h.id").
+		Limit(consentScanBatch).
+		Scan(&rows).Error; err != nil {
+		log.Printf("❌ Failed to load messages for consent keywords: %v", err)
+		return 0
+	}
+
+	cursor := state.LastHistoryID
+	for _, row := range rows {
+		if err := applyConsentKeyword(row); err != nil {
+			// Stop here so the message is read again on the next run.
+			log.Printf("❌ Failed to apply consent keyword of message %d: %v", row.ID, err)
+			break
+		}
+		if row.ID > cursor {
+			cursor = row.ID
+		}
+	}
+	if cursor > state.LastHistoryID {
+		if err := db.DB.Model(&models.ConsentScanState{}).
+			Where("id = 1").
+			Updates(map[string]interface{}{
+				"last_history_id": gorm.Expr("GREATEST(last_history_id, ?)", cursor),
+				"updated_at":      time.Now().UTC(),
+			}).Error; err != nil {
+			log.Printf("❌ Failed to store consent scan state: %v", err)
+		}
+	}
+	return len(rows)
+}
+
+// applyConsentKeyword records the consent change asked for by row, if any.
+func applyConsentKeyword(row consentScanRow) error {
+	kw := messaging.DetectConsentKeyword(row.Content)
+	if kw == "" {
+		return nil
+	}
+	// The session row may not be committed yet; the lookback reads the
+	// message again on the next runs.
+	if row.Phone == "" {
+		return nil
+	}
+	event := models.ConsentEventOptIn
+	if kw == messaging.ConsentOptOut {
+		event = models.ConsentEventOptOut
+	}
+	id := row.ID
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		return setConsent(tx, models.ConsentEvent{
+			Phone:     row.Phone,
+			Event:     event,
+			Source:    models.ConsentSourceInbound,
+			SessionID: row.SessionID,
+			Message:   row.Content,
+			HistoryID: &id,
+			CreatedAt: row.CreatedAt,
+		})
+	})
+}
@@ -0,0 +1,342 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/internal/phonenum"
+	"bestdoctors_service/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	contactLinkInterval = time.Minute
+	contactLinkBatch    = 500
+	defaultContactLimit = 50
+	maxContactLimit     = 200
+)
+
+// ContactRequest updates a contact; omitted fields are left as they are.
+type ContactRequest struct {
+	Name               *string `json:"name"`
+	Email              *string `json:"email"`
+	CPF                *string `json:"cpf"`
+	Convenio           *string `json:"convenio"`
+	PreferredSpecialty *string `json:"preferred_specialty"`
+}
+
+type ContactSummary struct {
+	models.Contact
+	Sessions      int64      `json:"sessions"`
+	LastMessageAt *time.Time `json:"last_message_at,omitempty"`
+}
+
+type ContactDetail struct {
+	models.Contact
+	Sessions []models.SessionPhone `json:"sessions"`
+}
+
+type ContactPage struct {
+	Contacts []ContactSummary `json:"contacts"`
+	Total    int64            `json:"total"`
+}
+
+// linkSessionContact attaches s to the contact for its phone, creating the
+// contact (named after the lead) the first time the phone is seen.
+func linkSessionContact(tx *gorm.DB, s *models.SessionPhone) error {
+	if s.ContactID != nil {
+		return nil
+	}
+	phone := phonenum.Key(s.Phone)
+	if phone == "" {
+		return nil
+	}
+	c := models.Contact{Phone: phone, Name: strings.TrimSpace(s.LeadName)}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&c).Error; err != nil {
+		return err
+	}
+	if c.ID == 0 {
+		if err := tx.Where("phone = ?", phone).First(&c).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Model(&models.SessionPhone{}).
+		Where("session_id = ?", s.SessionID).
+		UpdateColumn("contact_id", c.ID).Error; err != nil {
+		return err
+	}
+	s.ContactID = &c.ID
+	return nil
+}
+
+// StartContactLinker links sessions created outside the backend (n8n) to
+// their contact, until ctx is cancelled.
+func StartContactLinker(ctx context.Context) {
+	ticker := time.NewTicker(contactLinkInterval)
+	defer ticker.Stop()
+
+	for {
+		linkUnlinkedSessions()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// linkUnlinkedSessions links the oldest batch of unlinked sessions. Phones
+// without digits have no contact key and are skipped in SQL, or they would
+// fill every batch and hold newer sessions back.
+func linkUnlinkedSessions() {
+	var sessions []models.SessionPhone
+	if err := db.DB.
+		Where("contact_id IS NULL AND REGEXP_REPLACE(phone, '[^0-9]', '', 'g') <> ''").
+		Order("created_at").
+		Limit(contactLinkBatch).
+		Find(&sessions).Error; err != nil {
+		log.Printf("❌ Failed to load sessions without contact: %v", err)
+		return
+	}
+	for i := range sessions {
+		if err := linkSessionContact(db.DB, &sessions[i]); err != nil {
+			log.Printf("❌ Failed to link %s to a contact: %v", sessions[i].SessionID, err)
+		}
+	}
+}
+
+// digitsOnly drops everything but 0-9 (CPF and phone searches).
+func digitsOnly(s string) string {
+	return strings.Map(func(c rune) rune {
+		if c >= '0' && c <= '9' {
+			return c
+		}
+		return -1
+	}, s)
+}
+
+// contactKey identifies the person behind a session for unique-contact
+// counts; sessions not linked yet count on their own.
+func contactKey(sessionID string, contactID *uint) string {
+	if contactID != nil {
+		return "c" + strconv.FormatUint(uint64(*contactID), 10)
+	}
+	return "s" + sessionID
+}
+
+// ContactsHandler handles GET /contacts
+// ?q= matches name, email, phone or CPF digits. Paginated with
+// limit/offset, most recently active first.
+func ContactsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+
+	limit := defaultContactLimit
+	if n, err := strconv.Atoi(q.Get("limit")); err == nil && n > 0 {
+		limit = n
+	}
+	if limit > maxContactLimit {
+		limit = maxContactLimit
+	}
+	offset := 0
+	if n, err := strconv.Atoi(q.Get("offset")); err == nil && n > 0 {
+		offset = n
+	}
+
+	tx := db.DB.Table("contacts c")
+	if text := strings.TrimSpace(q.Get("q")); text != "" {
+		like := "%" + escapeLike(text) + "%"
+		cond := "c.name ILIKE ? OR c.email ILIKE ?"
+		args := []interface{}{like, like}
+		if digits := digitsOnly(text); digits != "" {
+			cond += " OR c.phone LIKE ? OR c.cpf = ?"
+			args = append(args, "%"+digits+"%", digits)
+		}
+		tx = tx.Where(cond, args...)
+	}
+	tx = tx.Session(&gorm.Session{})
+
+	page := ContactPage{Contacts: []ContactSummary{}}
+	if err := tx.Count(&page.Total).Error; err != nil {
+		http.Error(w, "failed to count contacts", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.
+		Select("c.*, COUNT(sp.session_id) AS sessions, MAX(sp.last_message_at) AS last_message_at").
+		Joins("LEFT JOIN session_phones sp ON sp.contact_id = c.id").
+		Group("c.id").
+		Order("MAX(sp.last_message_at) DESC NULLS LAST, c.id").
+		Limit(limit).
+		Offset(offset).
+		Scan(&page.Contacts).Error; err != nil {
+		http.Error(w, "failed to load contacts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// ContactHandler handles /contacts/{id} and /contacts/{id}/timeline
+// GET returns the contact with its sessions; PUT edits the profile. The
+// timeline merges the chat history of every session, oldest first, with
+// the same options as /chathistory (since, raw, events, notes).
+func ContactHandler(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/bestdoctors/contacts/"), "/")
+	idPart, action, _ := strings.Cut(rest, "/")
+	id, err := strconv.Atoi(idPart)
+	if err != nil || id <= 0 || (action != "" && action != "timeline") {
+		http.Error(w, "invalid contact path", http.StatusBadRequest)
+		return
+	}
+	var c models.Contact
+	if err := db.DB.First(&c, id).Error; err != nil {
+		http.Error(w, "contact not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case action == "timeline" && r.Method == http.MethodGet:
+		writeContactTimeline(w, r, &c)
+	case action == "" && r.Method == http.MethodGet:
+		writeContactDetail(w, &c)
+	case action == "" && r.Method == http.MethodPut:
+		updateContact(w, r, &c)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func contactSessions(contactID uint) []models.SessionPhone {
+	sessions := []models.SessionPhone{}
+	db.DB.Where("contact_id = ?", contactID).Order("created_at asc").Find(&sessions)
+	return sessions
+}
+
+func writeContactDetail(w http.ResponseWriter, c *models.Contact) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ContactDetail{Contact: *c, Sessions: contactSessions(c.ID)})
+}
+
+// validCPF checks the two check digits of an 11-digit CPF.
+func validCPF(cpf string) bool {
+	if len(cpf) != 11 || strings.Count(cpf, cpf[:1]) == 11 {
+		return false
+	}
+	for _, n := range []int{9, 10} {
+		sum := 0
+		for i := 0; i < n; i++ {
+			sum += int(cpf[i]-'0') * (n + 1 - i)
+		}
+		d := sum * 10 % 11 % 10
+		if d != int(cpf[n]-'0') {
+			return false
+		}
+	}
+	return true
+}
+
+func validateContact(c *models.Contact) error {
+	switch {
+	case utf8.RuneCountInString(c.Name) > 255:
+		return errors.New("name must have at most 255 characters")
+	case utf8.RuneCountInString(c.Convenio) > 100:
+		return errors.New("convenio must have at most 100 characters")
+	case utf8.RuneCountInString(c.PreferredSpecialty) > 100:
+		return errors.New("preferred_specialty must have at most 100 characters")
+	case c.CPF != "" && !validCPF(c.CPF):
+		return errors.New("invalid CPF")
+	}
+	if c.Email != "" {
+		if a, err := mail.ParseAddress(c.Email); err != nil || a.Address != c.Email || len(c.Email) > 255 {
+			return errors.New("invalid email")
+		}
+	}
+	return nil
+}
+
+func updateContact(w http.ResponseWriter, r *http.Request, c *models.Contact) {
+	var req ContactRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.Name != nil {
+		c.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Email != nil {
+		c.Email = strings.ToLower(strings.TrimSpace(*req.Email))
+	}
+	if req.CPF != nil {
+		c.CPF = digitsOnly(*req.CPF)
+	}
+	if req.Convenio != nil {
+		c.Convenio = strings.TrimSpace(*req.Convenio)
+	}
+	if req.PreferredSpecialty != nil {
+		c.PreferredSpecialty = strings.TrimSpace(*req.PreferredSpecialty)
+	}
+	if err := validateContact(c); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := db.DB.Save(c).Error; err != nil {
+		http.Error(w, "failed to update contact", http.StatusInternalServerError)
+		return
+	}
+	writeContactDetail(w, c)
+}
+
+func writeContactTimeline(w http.ResponseWriter, r *http.Request, c *models.Contact) {
+	q := r.URL.Query()
+	sessions := contactSessions(c.ID)
+	out := []HistoryResponse{}
+	if len(sessions) > 0 {
+		ids := make([]string, 0, len(sessions))
+		for _, s := range sessions {
+			ids = append(ids, s.SessionID)
+		}
+
+		tx := db.DB.Where("session_id IN ?", ids)
+		var sinceTime *time.Time
+		if since := q.Get("since"); since != "" {
+			if t, err := time.Parse(time.RFC3339, since); err == nil {
+				tx = tx.Where("created_at > ?", t)
+				sinceTime = &t
+			}
+		}
+		var rows []models.ChatHistory
+		tx.Order("created_at asc").Find(&rows)
+
+		out = historyItems(rows, q.Get("raw") == "1" || q.Get("raw") == "true")
+		for _, sid := range ids {
+			if q.Get("events") == "true" {
+				out = append(out, sessionEventHistory(sid, sinceTime)...)
+			}
+			if q.Get("notes") == "true" {
+				out = append(out, sessionNoteHistory(sid, sinceTime)...)
+			}
+		}
+		sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(out)
+}
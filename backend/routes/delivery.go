@@ -0,0 +1,128 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"bestdoctors_service/internal/config"
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/internal/messaging"
+	"bestdoctors_service/models"
+
+	"gorm.io/gorm"
+)
+
+// DeliveryInfo is the delivery state attached to outgoing messages in the
+// chat history response.
+type DeliveryInfo struct {
+	Status       string     `json:"status"`
+	ErrorCode    string     `json:"error_code,omitempty"`
+	ErrorMessage string     `json:"error_message,omitempty"`
+	DeliveredAt  *time.Time `json:"delivered_at,omitempty"`
+	ReadAt       *time.Time `json:"read_at,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// WhatsAppStatusHandler handles POST /webhooks/whatsapp/status
+// Twilio StatusCallback target; Meta receipts arrive on /webhooks/whatsapp.
+func WhatsAppStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form body", http.StatusBadRequest)
+		return
+	}
+	if !messaging.VerifyTwilioSignature(config.Env("TWILIO_AUTH_TOKEN"), webhookURL(r), r.PostForm, r.Header.Get("X-Twilio-Signature")) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	if u, ok := messaging.ParseTwilioStatus(r.PostForm); ok {
+		if err := applyStatusUpdate(u); err != nil {
+			log.Printf("❌ Failed to update delivery %s: %v", u.ProviderMessageID, err)
+			http.Error(w, "failed to update delivery", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// recordDelivery stores the provider message ID of an accepted send. It runs
+// in the transaction that writes the history row, so the two commit together.
+func recordDelivery(tx *gorm.DB, result messaging.SendResult, sessionID, to string, historyID *uint) error {
+	if result.ProviderMessageID == "" {
+		return nil
+	}
+	status := result.Status
+	if status == "" {
+		status = messaging.StatusQueued
+	}
+	return tx.Create(&models.MessageDelivery{
+		Provider:          result.Provider,
+		ProviderMessageID: result.ProviderMessageID,
+		SessionID:         sessionID,
+		ChatHistoryID:     historyID,
+		Recipient:         to,
+		Status:            status,
+	}).Error
+}
+
+// applyStatusUpdate moves a delivery forward; older or duplicated receipts
+// are ignored.
+func applyStatusUpdate(u messaging.StatusUpdate) error {
+	var d models.MessageDelivery
+	if err := db.DB.Where("provider_message_id = ?", u.ProviderMessageID).First(&d).Error; err != nil {
+		// Messages sent by n8n are not tracked here. Other errors go back
+		// to the provider, which retries the callback.
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if messaging.StatusRank(u.Status) <= messaging.StatusRank(d.Status) {
+		return nil
+	}
+
+	updates := map[string]interface{}{"status": u.Status}
+	switch u.Status {
+	case messaging.StatusDelivered:
+		updates["delivered_at"] = u.At
+	case messaging.StatusRead:
+		updates["read_at"] = u.At
+		if d.DeliveredAt == nil {
+			updates["delivered_at"] = u.At
+		}
+	case messaging.StatusFailed:
+		updates["error_code"] = u.ErrorCode
+		updates["error_message"] = u.ErrorMessage
+	}
+	return db.DB.Model(&d).Updates(updates).Error
+}
+
+// deliveriesByHistoryID loads the delivery state of the given history rows.
+func deliveriesByHistoryID(ids []uint) map[uint]*DeliveryInfo {
+	out := make(map[uint]*DeliveryInfo)
+	if len(ids) == 0 {
+		return out
+	}
+
+	var rows []models.MessageDelivery
+	db.DB.Where("chat_history_id IN ?", ids).Find(&rows)
+	for _, d := range rows {
+		if d.ChatHistoryID == nil {
+			continue
+		}
+		out[*d.ChatHistoryID] = &DeliveryInfo{
+			Status:       d.Status,
+			ErrorCode:    d.ErrorCode,
+			ErrorMessage: d.ErrorMessage,
+			DeliveredAt:  d.DeliveredAt,
+			ReadAt:       d.ReadAt,
+			UpdatedAt:    d.UpdatedAt,
+		}
+	}
+	return out
+}
@@ -0,0 +1,117 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/internal/realtime"
+	"bestdoctors_service/models"
+)
+
+// SSE event types.
+const (
+	eventMessage  = "message"
+	eventSession  = "session"
+	eventAIToggle = "ai_toggle"
+)
+
+const sseHeartbeat = 25 * time.Second
+
+var eventHub = realtime.NewHub()
+
+// StartEventListener feeds the SSE hub from Postgres notifications until ctx
+// is cancelled.
+func StartEventListener(ctx context.Context) {
+	realtime.Listen(ctx, db.DSN(), handleNotification)
+}
+
+func handleNotification(n realtime.Notification) {
+	// Nobody connected to this replica: skip the row lookups.
+	if eventHub.Len() == 0 {
+		return
+	}
+
+	switch n.Table {
+	case models.ChatHistory{}.TableName():
+		var h models.ChatHistory
+		if err := db.DB.First(&h, n.ID).Error; err != nil {
+			return
+		}
+		eventHub.Publish(realtime.Event{
+			Type:      eventMessage,
+			SessionID: h.SessionID,
+			Data: HistoryResponse{
+				ID:        h.ID,
+				SessionID: h.SessionID,
+				CreatedAt: h.CreatedAt,
+				Message:   parseMessage(h.Message),
+				Delivery:  deliveriesByHistoryID([]uint{h.ID})[h.ID],
+				Sender:    historySender(h.Message),
+			},
+		})
+
+	case models.SessionPhone{}.TableName():
+		var s models.SessionPhone
+		if err := db.DB.First(&s, "session_id = ?", n.SessionID).Error; err != nil {
+			return
+		}
+		eventHub.Publish(realtime.Event{Type: eventSession, SessionID: s.SessionID, Data: s})
+		if n.AIActiveChanged {
+			eventHub.Publish(realtime.Event{
+				Type:      eventAIToggle,
+				SessionID: s.SessionID,
+				Data:      map[string]interface{}{"session_id": s.SessionID, "ai_active": s.AIActive},
+			})
+		}
+	}
+}
+
+// EventsHandler handles GET /events
+// Streams "message", "session" and "ai_toggle" events as Server-Sent Events.
+// ?session_id= limits the stream to one conversation.
+func EventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sub := eventHub.Subscribe(r.URL.Query().Get("session_id"))
+	defer eventHub.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx: don't buffer the stream
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case e := <-sub.C:
+			data, err := json.Marshal(e.Data)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/models"
+)
+
+const (
+	flowStateInterval = time.Minute
+	flowStateBatch    = 200
+)
+
+// StartFlowStateWorker keeps session_phones.flow_state up to date for
+// sessions with messages newer than their last computation, until ctx is
+// cancelled. The first runs backfill existing sessions in batches.
+func StartFlowStateWorker(ctx context.Context) {
+	ticker := time.NewTicker(flowStateInterval)
+	defer ticker.Stop()
+
+	for {
+		for refreshFlowStates() == flowStateBatch {
+			if ctx.Err() != nil {
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshFlowStates recomputes up to flowStateBatch stale sessions and
+// returns how many it updated.
+func refreshFlowStates() int {
+	var stale []models.SessionPhone
+	if err := db.DB.
+		Where("flow_state_at IS NULL OR flow_state_at < last_message_at").
+		Order("last_message_at desc").
+		Limit(flowStateBatch).
+		Find(&stale).Error; err != nil {
+		log.Printf("❌ Failed to load sessions for flow state: %v", err)
+		return 0
+	}
+
+	updated := 0
+	for _, s := range stale {
+		var history []models.ChatHistory
+		db.DB.Where("session_id = ?", s.SessionID).Order("created_at ASC").Find(&history)
+		maxState, _ := sessionFlowSummary(history)
+
+		// Stamp with the last_message_at we read so a message arriving
+		// meanwhile gets picked up on the next run.
+		if err := db.DB.Model(&models.SessionPhone{}).
+			Where("session_id = ?", s.SessionID).
+			UpdateColumns(map[string]interface{}{
+				"flow_state":    maxState,
+				"flow_state_at": s.LastMessageAt,
+			}).Error; err != nil {
+			log.Printf("❌ Failed to store flow state for %s: %v", s.SessionID, err)
+			continue
+		}
+		updated++
+	}
+	return updated
+}
@@ -0,0 +1,342 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/internal/session"
+	"bestdoctors_service/middleware"
+	"bestdoctors_service/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errHandoffHeld     = errors.New("session is held by another attendant")
+	errHandoffNotFound = errors.New("session has no open handoff")
+)
+
+// HandoffView is a handoff with the session and attendant it refers to.
+// WaitingSeconds runs from queued to assigned (or now); HeldSeconds from
+// assigned to released (or now).
+type HandoffView struct {
+	models.SessionHandoff
+	Phone            string `json:"phone"`
+	LeadName         string `json:"lead_name"`
+	AIActive         bool   `json:"ai_active"`
+	AssignedUsername string `json:"assigned_username,omitempty"`
+	WaitingSeconds   int64  `json:"waiting_seconds"`
+	HeldSeconds      int64  `json:"held_seconds"`
+}
+
+type AssignHandoffRequest struct {
+	UserID int `json:"user_id"`
+}
+
+func sessionUser(r *http.Request) *session.SessionData {
+	sd, _ := r.Context().Value(middleware.SessionDataKey).(*session.SessionData)
+	return sd
+}
+
+func isPanelAdmin(sd *session.SessionData) bool {
+	return sd != nil && (sd.Role == "admin" || sd.Role == "superadmin")
+}
+
+// openHandoff queues sessionID for an attendant unless it already has an
+// open handoff, which is returned as is.
+func openHandoff(tx *gorm.DB, sessionID string, at time.Time) (*models.SessionHandoff, error) {
+	h := models.SessionHandoff{SessionID: sessionID, Status: models.HandoffQueued, QueuedAt: at}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "session_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "status <> 'released'"}}},
+		DoNothing:   true,
+	}).Create(&h).Error; err != nil {
+		return nil, err
+	}
+	var open models.SessionHandoff
+	if err := tx.Where("session_id = ? AND status <> ?", sessionID, models.HandoffReleased).
+		First(&open).Error; err != nil {
+		return nil, err
+	}
+	return &open, nil
+}
+
+// releaseHandoff closes the session's open handoff, if any. Call it before
+// turning the AI back on: otherwise the handoff trigger on session_phones
+// releases the handoff first, without released_by.
+func releaseHandoff(tx *gorm.DB, sessionID string, by *int, at time.Time) error {
+	return tx.Model(&models.SessionHandoff{}).
+		Where("session_id = ? AND status <> ?", sessionID, models.HandoffReleased).
+		Updates(map[string]interface{}{
+			"status":      models.HandoffReleased,
+			"released_at": at,
+			"released_by": by,
+		}).Error
+}
+
+// takeHandoff gives sessionID to userID, turning the AI off and queueing the
+// session first if needed. Without force it fails when another attendant
+// already holds the session.
+func takeHandoff(sessionID string, userID int, actor *session.SessionData, force bool) (*models.SessionHandoff, error) {
+	var h *models.SessionHandoff
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		reason := "handoff claimed"
+		if force {
+			reason = "handoff assigned"
+		}
+		if _, _, err := setAIActive(tx, sessionID, false, nil, actor, reason); err != nil {
+			return err
+		}
+
+		open, err := openHandoff(tx, sessionID, now)
+		if err != nil {
+			return err
+		}
+		if open.Status == models.HandoffAssigned && open.AssignedUserID != nil && *open.AssignedUserID == userID {
+			h = open
+			return nil
+		}
+
+		q := tx.Model(&models.SessionHandoff{}).Where("id = ?", open.ID)
+		if !force {
+			q = q.Where("status = ?", models.HandoffQueued)
+		}
+		res := q.Updates(map[string]interface{}{
+			"status":           models.HandoffAssigned,
+			"assigned_user_id": userID,
+			"assigned_by":      actor.UserID,
+			"assigned_at":      now,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errHandoffHeld
+		}
+		h = &models.SessionHandoff{}
+		return tx.First(h, open.ID).Error
+	})
+	return h, err
+}
+
+func handoffViews(handoffs []models.SessionHandoff) []HandoffView {
+	sids := make([]string, 0, len(handoffs))
+	uids := []int{}
+	for _, h := range handoffs {
+		sids = append(sids, h.SessionID)
+		if h.AssignedUserID != nil {
+			uids = append(uids, *h.AssignedUserID)
+		}
+	}
+
+	sessions := map[string]models.SessionPhone{}
+	if len(sids) > 0 {
+		var rows []models.SessionPhone
+		db.DB.Where("session_id IN ?", sids).Find(&rows)
+		for _, s := range rows {
+			sessions[s.SessionID] = s
+		}
+	}
+	usernames := map[int]string{}
+	if len(uids) > 0 {
+		var users []models.User
+		db.DB.Select("id", "username").Where("id IN ?", uids).Find(&users)
+		for _, u := range users {
+			usernames[u.ID] = u.Username
+		}
+	}
+
+	now := time.Now().UTC()
+	out := make([]HandoffView, 0, len(handoffs))
+	for _, h := range handoffs {
+		v := HandoffView{SessionHandoff: h}
+		if s, ok := sessions[h.SessionID]; ok {
+			v.Phone = s.Phone
+			v.LeadName = s.LeadName
+			v.AIActive = s.AIActive
+		}
+		if h.AssignedUserID != nil {
+			v.AssignedUsername = usernames[*h.AssignedUserID]
+		}
+
+		waitEnd := now
+		if h.AssignedAt != nil {
+			waitEnd = *h.AssignedAt
+		} else if h.ReleasedAt != nil {
+			waitEnd = *h.ReleasedAt
+		}
+		v.WaitingSeconds = int64(waitEnd.Sub(h.QueuedAt).Seconds())
+		if h.AssignedAt != nil {
+			holdEnd := now
+			if h.ReleasedAt != nil {
+				holdEnd = *h.ReleasedAt
+			}
+			v.HeldSeconds = int64(holdEnd.Sub(*h.AssignedAt).Seconds())
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+// HandoffsHandler handles GET /handoffs
+// Lists open handoffs, oldest first. Filters: status=queued|assigned,
+// user_id= (or mine=true) for the sessions one attendant holds.
+func HandoffsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+
+	tx := db.DB.Where("status <> ?", models.HandoffReleased)
+	switch st := q.Get("status"); st {
+	case "":
+	case models.HandoffQueued, models.HandoffAssigned:
+		tx = tx.Where("status = ?", st)
+	default:
+		http.Error(w, "status must be queued or assigned", http.StatusBadRequest)
+		return
+	}
+	if q.Get("mine") == "true" {
+		if sd := sessionUser(r); sd != nil {
+			tx = tx.Where("assigned_user_id = ?", sd.UserID)
+		}
+	} else if uid := q.Get("user_id"); uid != "" {
+		tx = tx.Where("assigned_user_id = ?", uid)
+	}
+
+	var handoffs []models.SessionHandoff
+	tx.Order("queued_at asc").Find(&handoffs)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(handoffViews(handoffs))
+}
+
+// HandoffHandler handles /handoffs/{session_id}[/claim|/assign|/release]
+//
+//	GET             current handoff of the session (404 when the AI has it)
+//	POST  /claim    take the session for the logged-in attendant
+//	POST  /assign   {"user_id": N} hand it to someone else (admins only)
+//	POST  /release  end the handoff and give the session back to the AI
+func HandoffHandler(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/bestdoctors/handoffs/"), "/")
+	parts := strings.SplitN(rest, "/", 2)
+	sid := parts[0]
+	if sid == "" {
+		http.Error(w, "session_id is required", http.StatusBadRequest)
+		return
+	}
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		getHandoff(w, sid)
+	case action == "claim" && r.Method == http.MethodPost:
+		claimHandoff(w, r, sid)
+	case action == "assign" && r.Method == http.MethodPost:
+		assignHandoff(w, r, sid)
+	case action == "release" && r.Method == http.MethodPost:
+		releaseHandoffHandler(w, r, sid)
+	case action == "" || action == "claim" || action == "assign" || action == "release":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func getHandoff(w http.ResponseWriter, sid string) {
+	var h models.SessionHandoff
+	if err := db.DB.Where("session_id = ? AND status <> ?", sid, models.HandoffReleased).First(&h).Error; err != nil {
+		http.Error(w, errHandoffNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	writeHandoff(w, &h)
+}
+
+func claimHandoff(w http.ResponseWriter, r *http.Request, sid string) {
+	sd := sessionUser(r)
+	if sd == nil || sd.UserID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h, err := takeHandoff(sid, sd.UserID, sd, false)
+	respondHandoff(w, h, err)
+}
+
+func assignHandoff(w http.ResponseWriter, r *http.Request, sid string) {
+	sd := sessionUser(r)
+	if !isPanelAdmin(sd) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	var req AssignHandoffRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID <= 0 {
+		http.Error(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+	var u models.User
+	if err := db.DB.Where("id = ? AND is_active = ?", req.UserID, true).First(&u).Error; err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	h, err := takeHandoff(sid, u.ID, sd, true)
+	respondHandoff(w, h, err)
+}
+
+func releaseHandoffHandler(w http.ResponseWriter, r *http.Request, sid string) {
+	sd := sessionUser(r)
+	var h models.SessionHandoff
+	if err := db.DB.Where("session_id = ? AND status <> ?", sid, models.HandoffReleased).First(&h).Error; err != nil {
+		http.Error(w, errHandoffNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if h.AssignedUserID != nil && (sd == nil || *h.AssignedUserID != sd.UserID) && !isPanelAdmin(sd) {
+		http.Error(w, errHandoffHeld.Error(), http.StatusConflict)
+		return
+	}
+
+	var by *int
+	if sd != nil {
+		by = &sd.UserID
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := releaseHandoff(tx, sid, by, time.Now().UTC()); err != nil {
+			return err
+		}
+		_, _, err := setAIActive(tx, sid, true, nil, sd, "handoff released")
+		return err
+	})
+	if err != nil {
+		http.Error(w, "failed to release session", http.StatusInternalServerError)
+		return
+	}
+	db.DB.First(&h, h.ID)
+	writeHandoff(w, &h)
+}
+
+func respondHandoff(w http.ResponseWriter, h *models.SessionHandoff, err error) {
+	switch {
+	case err == nil:
+		writeHandoff(w, h)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "session not found", http.StatusNotFound)
+	case errors.Is(err, errHandoffHeld):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "failed to update handoff", http.StatusInternalServerError)
+	}
+}
+
+func writeHandoff(w http.ResponseWriter, h *models.SessionHandoff) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(handoffViews([]models.SessionHandoff{*h})[0])
+}
@@ -0,0 +1,143 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"bestdoctors_service/internal/messaging"
+	"bestdoctors_service/internal/storage"
+)
+
+// maxMediaSize is WhatsApp's limit for documents sent through Twilio.
+const maxMediaSize = 16 << 20
+
+var allowedMediaTypes = map[string]bool{
+	"image/jpeg":         true,
+	"image/png":          true,
+	"image/webp":         true,
+	"application/pdf":    true,
+	"audio/mpeg":         true,
+	"audio/ogg":          true,
+	"video/mp4":          true,
+	"text/plain":         true,
+	"application/msword": true,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": true,
+	"application/vnd.ms-excel": true,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": true,
+}
+
+var mediaStore storage.Store
+
+// InitMediaStore selects the media storage backend from the environment.
+func InitMediaStore() error {
+	s, err := storage.NewStoreFromEnv()
+	if err != nil {
+		return err
+	}
+	mediaStore = s
+	return nil
+}
+
+// MediaFileHandler serves locally stored media under /media/. It returns nil
+// when media lives in S3.
+func MediaFileHandler() http.Handler {
+	if local, ok := mediaStore.(*storage.LocalStore); ok {
+		return local.Handler("/media/")
+	}
+	return nil
+}
+
+type uploadedFile struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// parseMultipartSend reads a multipart/form-data send request: the same
+// fields as SendMessageRequest (vars and params as JSON strings, send_at as
+// RFC3339) plus an optional "file".
+func parseMultipartSend(r *http.Request) (SendMessageRequest, *uploadedFile, error) {
+	var req SendMessageRequest
+	r.Body = http.MaxBytesReader(nil, r.Body, maxMediaSize+(1<<20))
+	if err := r.ParseMultipartForm(maxMediaSize); err != nil {
+		return req, nil, errors.New("invalid multipart body or file too large")
+	}
+
+	req.To = r.FormValue("to")
+	req.Message = r.FormValue("message")
+	req.SessionID = r.FormValue("session_id")
+	req.Template = r.FormValue("template")
+	req.Language = r.FormValue("language")
+	if v := r.FormValue("vars"); v != "" {
+		if err := json.Unmarshal([]byte(v), &req.Vars); err != nil {
+			return req, nil, errors.New("vars must be a JSON object")
+		}
+	}
+	if v := r.FormValue("params"); v != "" {
+		if err := json.Unmarshal([]byte(v), &req.Params); err != nil {
+			return req, nil, errors.New("params must be a JSON object")
+		}
+	}
+	if v := r.FormValue("send_at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return req, nil, errors.New("invalid 'send_at' format, must be RFC3339")
+		}
+		req.SendAt = &t
+	}
+
+	file, header, err := r.FormFile("file")
+	if errors.Is(err, http.ErrMissingFile) {
+		return req, nil, nil
+	}
+	if err != nil {
+		return req, nil, errors.New("invalid file upload")
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxMediaSize+1))
+	if err != nil {
+		return req, nil, errors.New("failed to read file")
+	}
+	if len(data) > maxMediaSize {
+		return req, nil, fmt.Errorf("file exceeds %d MB", maxMediaSize>>20)
+	}
+
+	contentType := header.Header.Get("Content-Type")
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = http.DetectContentType(data)
+	}
+	contentType = strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	if !allowedMediaTypes[contentType] {
+		return req, nil, fmt.Errorf("unsupported file type %q", contentType)
+	}
+
+	return req, &uploadedFile{Filename: header.Filename, ContentType: contentType, Data: data}, nil
+}
+
+// storeUpload puts the file in media storage and describes it for the provider.
+func storeUpload(ctx context.Context, f *uploadedFile) (*messaging.Media, error) {
+	if mediaStore == nil {
+		return nil, errors.New("media storage not configured")
+	}
+	key, err := storage.NewKey(f.Filename)
+	if err != nil {
+		return nil, err
+	}
+	url, err := mediaStore.Put(ctx, key, f.ContentType, f.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &messaging.Media{
+		URL:         url,
+		ContentType: f.ContentType,
+		Filename:    f.Filename,
+		Size:        int64(len(f.Data)),
+	}, nil
+}
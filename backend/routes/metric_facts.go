@@ -0,0 +1,171 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	metricFactsInterval = 30 * time.Second
+	metricFactsBatch    = 200
+	metricFactsLookback = 1000
+)
+
+// StartMetricFactsWorker keeps session_metric_facts in step with
+// n8n_chat_histories until ctx is cancelled, recomputing every session
+// that got messages since its row was written. The first runs backfill
+// the existing sessions in batches.
+func StartMetricFactsWorker(ctx context.Context) {
+	ticker := time.NewTicker(metricFactsInterval)
+	defer ticker.Stop()
+
+	for {
+		for refreshMetricFacts() == metricFactsBatch {
+			if ctx.Err() != nil {
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshMetricFacts recomputes up to metricFactsBatch stale sessions and
+// returns how many it looked at. Sessions are taken oldest activity first,
+// so the lookback below the newest stored id never skips one; as in the
+// search indexer, it also covers ids committed out of order.
+func refreshMetricFacts() int {
+	var maxID uint
+	db.DB.Model(&models.SessionMetricFacts{}).Select("COALESCE(MAX(last_history_id), 0)").Scan(&maxID)
+	floor := 0
+	if maxID > metricFactsLookback {
+		floor = int(maxID) - metricFactsLookback
+	}
+
+	var stale []string
+	if err := db.DB.Table("n8n_chat_histories h").
+		Where("h.id > ?", floor).
+		Group("h.session_id").
+		Having("MAX(h.id) > COALESCE((SELECT mf.last_history_id FROM session_metric_facts mf WHERE mf.session_id = h.session_id), 0)").
+		Order("MAX(h.id)").
+		Limit(metricFactsBatch).
+		Pluck("h.session_id", &stale).Error; err != nil {
+		log.Printf("❌ Failed to load sessions for metric facts: %v", err)
+		return 0
+	}
+
+	for _, sid := range stale {
+		if err := refreshSessionFacts(sid); err != nil {
+			log.Printf("❌ Failed to store metric facts for %s: %v", sid, err)
+		}
+	}
+	return len(stale)
+}
+
+func refreshSessionFacts(sid string) error {
+	var history []models.ChatHistory
+	if err := db.DB.Where("session_id = ?", sid).Order("created_at ASC, id ASC").Find(&history).Error; err != nil {
+		return err
+	}
+	facts, recaptures := computeSessionFacts(sid, history)
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&facts).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("session_id = ?", sid).Delete(&models.SessionMetricRecapture{}).Error; err != nil {
+			return err
+		}
+		if len(recaptures) == 0 {
+			return nil
+		}
+		return tx.Create(&recaptures).Error
+	})
+}
+
+// computeSessionFacts derives the metric facts from a session's history,
+// oldest first, with the same rules the per-session metrics used: flow
+// state and finalizar as in sessionFlowSummary, engagement from the lead's
+// turns and reengagement as a plain lead message after a recapture.
+func computeSessionFacts(sid string, history []models.ChatHistory) (models.SessionMetricFacts, []models.SessionMetricRecapture) {
+	f := models.SessionMetricFacts{SessionID: sid, MessageCount: len(history)}
+	if len(history) == 0 {
+		return f, nil
+	}
+	first, last := history[0].CreatedAt, history[len(history)-1].CreatedAt
+	f.FirstMessageAt, f.LastMessageAt = &first, &last
+
+	f.MaxFlowState, f.Finalizar = sessionFlowSummary(history)
+	bot := withoutAttendantTurns(history)
+	f.AttendantCount = len(history) - len(bot)
+	f.BotMaxFlowState, f.BotFinalizar = sessionFlowSummary(bot)
+
+	campaignIdx := map[uint]int{}
+	lastPlainHuman := -1
+	for i, entry := range history {
+		if entry.ID > f.LastHistoryID {
+			f.LastHistoryID = entry.ID
+		}
+		var rm recapRawMessage
+		if err := json.Unmarshal([]byte(entry.Message), &rm); err != nil || rm.Type != "human" {
+			continue
+		}
+		f.HumanCount++
+		if !strings.HasPrefix(rm.Content, recapturePrefix) {
+			lastPlainHuman = i
+			continue
+		}
+		if f.RecaptureIndex == nil {
+			idx := i
+			f.RecaptureIndex = &idx
+		}
+		if id, ok := recaptureCampaignID(rm.Content); ok {
+			if _, seen := campaignIdx[id]; !seen {
+				campaignIdx[id] = i
+			}
+		}
+	}
+	f.Reengaged = f.RecaptureIndex != nil && lastPlainHuman > *f.RecaptureIndex
+
+	recaptures := make([]models.SessionMetricRecapture, 0, len(campaignIdx))
+	for id, idx := range campaignIdx {
+		recaptures = append(recaptures, models.SessionMetricRecapture{
+			SessionID:      sid,
+			CampaignID:     id,
+			RecaptureIndex: idx,
+			Reengaged:      lastPlainHuman > idx,
+		})
+	}
+	return f, recaptures
+}
+
+// recaptureCampaignID reads the campaign id out of a campaignMarkerPrefix
+// message.
+func recaptureCampaignID(content string) (uint, bool) {
+	rest := strings.TrimPrefix(content, recapturePrefix+"Campaign #")
+	if rest == content {
+		return 0, false
+	}
+	digits, _, ok := strings.Cut(rest, ":")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(digits, 10, 32)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
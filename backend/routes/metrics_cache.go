@@ -0,0 +1,220 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/models"
+
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// Entries younger than metricsCacheFresh are served as they are; up to
+	// metricsCacheMaxStale they are served while a refresh runs in the
+	// background; older ones are recomputed before answering.
+	metricsCacheFresh    = 10 * time.Minute
+	metricsCacheMaxStale = 24 * time.Hour
+	metricsCacheLease    = 2 * time.Minute
+)
+
+// Cache-Status values. REFRESH marks entries recomputed on request by
+// MetricsCacheHandler.
+const (
+	cacheHit     = "HIT"
+	cacheStale   = "STALE"
+	cacheMiss    = "MISS"
+	cacheRefresh = "REFRESH"
+)
+
+// metricsCacheParams identifies a cached metric: the metric name and the
+// filter it was computed with. It is stored with the entry so the entry can
+// be recomputed without the original request.
+type metricsCacheParams struct {
+	Metric           string   `json:"metric"`
+	Tags             []string `json:"tags,omitempty"`
+	ExcludeAttendant bool     `json:"exclude_attendant,omitempty"`
+	// Sessions asks reengagement for the session id lists.
+	Sessions bool `json:"sessions,omitempty"`
+}
+
+func metricsCacheParamsFromQuery(r *http.Request, metric string) metricsCacheParams {
+	f := metricsFilterFromQuery(r)
+	return metricsCacheParams{
+		Metric:           metric,
+		Tags:             f.Tags,
+		ExcludeAttendant: f.ExcludeAttendant,
+		Sessions:         metric == "reengagement" && r.URL.Query().Get("sessions") == "true",
+	}
+}
+
+func (p metricsCacheParams) filter() MetricsFilter {
+	return MetricsFilter{Tags: p.Tags, ExcludeAttendant: p.ExcludeAttendant}
+}
+
+func (p metricsCacheParams) key() string {
+	key := p.filter().cacheKey(p.Metric)
+	if p.Sessions {
+		key += ":sessions"
+	}
+	return key
+}
+
+func (p metricsCacheParams) compute() (interface{}, error) {
+	f := p.filter()
+	switch p.Metric {
+	case "abandonment":
+		return CalculateAbandonmentMetricsFiltered(db.SupabaseDB, f)
+	case "flowdepth":
+		return CalculateFlowDepthMetricsFiltered(db.SupabaseDB, f)
+	case "reengagement":
+		return CalculateReengagementMetricsFiltered(db.SupabaseDB, p.Sessions, f, 0)
+	}
+	return nil, fmt.Errorf("unknown metric %q", p.Metric)
+}
+
+// refreshMetricsCache computes p and stores the result, releasing any
+// refresh lease on the entry.
+func refreshMetricsCache(p metricsCacheParams) (models.MetricsCache, error) {
+	result, err := p.compute()
+	if err != nil {
+		return models.MetricsCache{}, err
+	}
+	payload, err := json.Marshal(result)
+	if err != nil {
+		return models.MetricsCache{}, err
+	}
+	params, _ := json.Marshal(p)
+	entry := models.MetricsCache{
+		MetricKey:       p.key(),
+		Params:          params,
+		Payload:         payload,
+		LastRefreshedAt: time.Now().UTC(),
+	}
+	err = db.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&entry).Error
+	return entry, err
+}
+
+// claimMetricsRefresh takes the refresh lease on key, so only one replica
+// recomputes a stale entry at a time.
+func claimMetricsRefresh(key string) bool {
+	now := time.Now().UTC()
+	res := db.DB.Model(&models.MetricsCache{}).
+		Where("metric_key = ? AND (refreshing_until IS NULL OR refreshing_until < ?)", key, now).
+		UpdateColumn("refreshing_until", now.Add(metricsCacheLease))
+	return res.Error == nil && res.RowsAffected == 1
+}
+
+// serveCachedMetric answers a metric request from bestdoctors_metrics_cache
+// (stale-while-revalidate) and reports what happened in the Cache-Status
+// and Last-Refreshed headers.
+func serveCachedMetric(w http.ResponseWriter, p metricsCacheParams) {
+	key := p.key()
+	status := cacheMiss
+
+	var entry models.MetricsCache
+	err := db.DB.Where("metric_key = ?", key).First(&entry).Error
+	age := time.Since(entry.LastRefreshedAt)
+	switch {
+	case err == nil && age <= metricsCacheFresh:
+		status = cacheHit
+	case err == nil && age <= metricsCacheMaxStale:
+		status = cacheStale
+		if claimMetricsRefresh(key) {
+			go func() {
+				if _, err := refreshMetricsCache(p); err != nil {
+					log.Printf("❌ Failed to refresh metrics cache %s: %v", key, err)
+				}
+			}()
+		}
+	default:
+		if entry, err = refreshMetricsCache(p); err != nil {
+			log.Printf("❌ Failed to calculate %s: %v", key, err)
+			http.Error(w, "failed to calculate "+p.Metric, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Status", status)
+	w.Header().Set("Last-Refreshed", entry.LastRefreshedAt.UTC().Format(http.TimeFormat))
+	w.Write(entry.Payload)
+}
+
+// MetricsCacheEntry is a cache entry as listed by /metrics/cache.
+type MetricsCacheEntry struct {
+	MetricKey       string             `json:"metric_key"`
+	Params          metricsCacheParams `json:"params"`
+	LastRefreshedAt time.Time          `json:"last_refreshed_at"`
+	AgeSeconds      int64              `json:"age_seconds"`
+	Status          string             `json:"status,omitempty"`
+	Error           string             `json:"error,omitempty"`
+}
+
+// MetricsCacheHandler handles /metrics/cache (panel admins only)
+// GET lists the cached entries; POST recomputes them now and DELETE drops
+// them, so the next request computes from scratch. ?metric= limits POST and
+// DELETE to one metric (abandonment, flowdepth, reengagement).
+func MetricsCacheHandler(w http.ResponseWriter, r *http.Request) {
+	if !isPanelAdmin(sessionUser(r)) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	metric := r.URL.Query().Get("metric")
+
+	switch r.Method {
+	case http.MethodGet, http.MethodPost:
+	case http.MethodDelete:
+		q := db.DB.Where("1 = 1")
+		if metric != "" {
+			q = db.DB.Where("params ->> 'metric' = ?", metric)
+		}
+		res := q.Delete(&models.MetricsCache{})
+		if res.Error != nil {
+			http.Error(w, "failed to clear metrics cache", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int64{"deleted": res.RowsAffected})
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var entries []models.MetricsCache
+	tx := db.DB.Order("metric_key")
+	if metric != "" {
+		tx = tx.Where("params ->> 'metric' = ?", metric)
+	}
+	if err := tx.Find(&entries).Error; err != nil {
+		http.Error(w, "failed to load metrics cache", http.StatusInternalServerError)
+		return
+	}
+
+	out := make([]MetricsCacheEntry, 0, len(entries))
+	for _, e := range entries {
+		item := MetricsCacheEntry{MetricKey: e.MetricKey}
+		_ = json.Unmarshal(e.Params, &item.Params)
+		// Entries written before params were stored cannot be recomputed;
+		// they expire on their own.
+		if r.Method == http.MethodPost && item.Params.Metric != "" {
+			if fresh, err := refreshMetricsCache(item.Params); err != nil {
+				log.Printf("❌ Failed to refresh metrics cache %s: %v", e.MetricKey, err)
+				item.Error = err.Error()
+			} else {
+				e = fresh
+				item.Status = cacheRefresh
+			}
+		}
+		item.LastRefreshedAt = e.LastRefreshedAt
+		item.AgeSeconds = int64(time.Since(e.LastRefreshedAt).Seconds())
+		out = append(out, item)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
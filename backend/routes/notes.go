@@ -0,0 +1,213 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/internal/session"
+	"bestdoctors_service/models"
+
+	"gorm.io/gorm"
+)
+
+const maxNoteLength = 5000
+
+type NoteRequest struct {
+	SessionID string `json:"session_id"`
+	Body      string `json:"body"`
+}
+
+type NoteDetail struct {
+	models.SessionNote
+	Revisions []models.SessionNoteRevision `json:"revisions"`
+}
+
+// NotesHandler handles /notes
+// GET ?session_id= lists the session's notes; POST creates one.
+func NotesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		sid := r.URL.Query().Get("session_id")
+		if sid == "" {
+			http.Error(w, "session_id is required", http.StatusBadRequest)
+			return
+		}
+		notes := []models.SessionNote{}
+		db.DB.Where("session_id = ?", sid).Order("created_at asc").Find(&notes)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(notes)
+	case http.MethodPost:
+		createNote(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// NoteHandler handles /notes/{id}
+// GET returns the note with its edit history; PUT edits it; DELETE removes
+// it. Only the author or an admin may change a note.
+func NoteHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/bestdoctors/notes/"), "/"))
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid note id", http.StatusBadRequest)
+		return
+	}
+	var n models.SessionNote
+	if err := db.DB.First(&n, id).Error; err != nil {
+		http.Error(w, "note not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeNoteDetail(w, &n)
+	case http.MethodPut:
+		updateNote(w, r, &n)
+	case http.MethodDelete:
+		deleteNote(w, r, &n)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func validNoteBody(body string) bool {
+	body = strings.TrimSpace(body)
+	return body != "" && len(body) <= maxNoteLength
+}
+
+func canEditNote(sd *session.SessionData, n *models.SessionNote) bool {
+	if isPanelAdmin(sd) {
+		return true
+	}
+	return sd != nil && n.AuthorID != nil && *n.AuthorID == sd.UserID
+}
+
+func createNote(w http.ResponseWriter, r *http.Request) {
+	var req NoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SessionID == "" {
+		http.Error(w, "session_id and body are required", http.StatusBadRequest)
+		return
+	}
+	if !validNoteBody(req.Body) {
+		http.Error(w, "body must have 1 to 5000 characters", http.StatusBadRequest)
+		return
+	}
+	var count int64
+	db.DB.Model(&models.SessionPhone{}).Where("session_id = ?", req.SessionID).Count(&count)
+	if count == 0 {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	n := models.SessionNote{SessionID: req.SessionID, Body: strings.TrimSpace(req.Body)}
+	if sd := sessionUser(r); sd != nil {
+		if sd.UserID > 0 {
+			n.AuthorID = &sd.UserID
+		}
+		n.AuthorUsername = sd.Username
+	}
+	if err := db.DB.Create(&n).Error; err != nil {
+		http.Error(w, "failed to create note", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(n)
+}
+
+// saveNoteRevision stores the current text of n before it changes.
+func saveNoteRevision(tx *gorm.DB, n *models.SessionNote, sd *session.SessionData) error {
+	rev := models.SessionNoteRevision{NoteID: n.ID, Body: n.Body, EditedAt: time.Now().UTC()}
+	if sd != nil {
+		if sd.UserID > 0 {
+			rev.EditedBy = &sd.UserID
+		}
+		rev.EditedUsername = sd.Username
+	}
+	return tx.Create(&rev).Error
+}
+
+func updateNote(w http.ResponseWriter, r *http.Request, n *models.SessionNote) {
+	sd := sessionUser(r)
+	if !canEditNote(sd, n) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	var req NoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !validNoteBody(req.Body) {
+		http.Error(w, "body must have 1 to 5000 characters", http.StatusBadRequest)
+		return
+	}
+	body := strings.TrimSpace(req.Body)
+	if body == n.Body {
+		writeNoteDetail(w, n)
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveNoteRevision(tx, n, sd); err != nil {
+			return err
+		}
+		n.Body = body
+		return tx.Save(n).Error
+	})
+	if err != nil {
+		http.Error(w, "failed to update note", http.StatusInternalServerError)
+		return
+	}
+	writeNoteDetail(w, n)
+}
+
+func deleteNote(w http.ResponseWriter, r *http.Request, n *models.SessionNote) {
+	sd := sessionUser(r)
+	if !canEditNote(sd, n) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	// Soft delete: the text stays in the revisions for the record.
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveNoteRevision(tx, n, sd); err != nil {
+			return err
+		}
+		return tx.Delete(n).Error
+	})
+	if err != nil {
+		http.Error(w, "failed to delete note", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeNoteDetail(w http.ResponseWriter, n *models.SessionNote) {
+	detail := NoteDetail{SessionNote: *n, Revisions: []models.SessionNoteRevision{}}
+	db.DB.Where("note_id = ?", n.ID).Order("edited_at asc").Find(&detail.Revisions)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+// sessionNoteHistory renders notes as history items for the chat timeline
+// (?notes=true). Notes never go into n8n_chat_histories.
+func sessionNoteHistory(sid string, since *time.Time) []HistoryResponse {
+	var notes []models.SessionNote
+	tx := db.DB.Where("session_id = ?", sid)
+	if since != nil {
+		tx = tx.Where("created_at > ?", *since)
+	}
+	tx.Order("created_at asc").Find(&notes)
+
+	out := make([]HistoryResponse, 0, len(notes))
+	for i := range notes {
+		out = append(out, HistoryResponse{
+			SessionID: sid,
+			CreatedAt: notes[i].CreatedAt,
+			Note:      &notes[i],
+		})
+	}
+	return out
+}
@@ -0,0 +1,337 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/internal/messaging"
+	"bestdoctors_service/internal/outbox"
+	"bestdoctors_service/internal/phonenum"
+	"bestdoctors_service/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	outboxPollInterval   = 2 * time.Second
+	outboxBatchSize      = 20
+	outboxStaleSending   = 5 * time.Minute
+	outboxDefaultRetries = 8
+)
+
+var outboxRetry = outbox.Retry{BaseBackoff: 5 * time.Second, MaxBackoff: 30 * time.Minute}
+
+// enqueueOutbound inserts m as pending. When m carries an idempotency key
+// that was already used, m is replaced by the stored row and duplicate is true.
+func enqueueOutbound(m *models.OutboundMessage) (duplicate bool, err error) {
+	to, err := phonenum.Normalize(m.Recipient)
+	if err != nil {
+		return false, fmt.Errorf("invalid recipient %q: %w", m.Recipient, err)
+	}
+	m.Recipient = to
+
+	if m.IdempotencyKey != nil {
+		existing, err := findOutboundByKey(*m.IdempotencyKey)
+		if err != nil {
+			return false, err
+		}
+		if existing != nil {
+			*m = *existing
+			return true, nil
+		}
+	}
+
+	m.Status = models.OutboundPending
+	if m.MaxAttempts == 0 {
+		m.MaxAttempts = outboxDefaultRetries
+	}
+	if m.NextAttemptAt.IsZero() {
+		m.NextAttemptAt = time.Now().UTC()
+	}
+	if err := db.DB.Create(m).Error; err != nil {
+		// Two requests with the same key raced; return the winner.
+		if m.IdempotencyKey != nil {
+			if existing, _ := findOutboundByKey(*m.IdempotencyKey); existing != nil {
+				*m = *existing
+				return true, nil
+			}
+		}
+		return false, err
+	}
+	return false, nil
+}
+
+// findOutboundByKey returns the row stored under an idempotency key, or nil
+// when the key was not used yet.
+func findOutboundByKey(key string) (*models.OutboundMessage, error) {
+	var m models.OutboundMessage
+	err := db.DB.Where("idempotency_key = ?", key).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// claimOutbound moves a pending row to sending. It returns false when
+// another worker (or request) got there first.
+func claimOutbound(id uint) bool {
+	res := db.DB.Model(&models.OutboundMessage{}).
+		Where("id = ? AND status = ?", id, models.OutboundPending).
+		Update("status", models.OutboundSending)
+	return res.Error == nil && res.RowsAffected == 1
+}
+
+// dispatchOutbound sends a claimed row. The ChatHistory row is only written
+// once the provider accepted the message; failures are rescheduled with
+// exponential backoff until MaxAttempts, then dead-lettered.
+func dispatchOutbound(ctx context.Context, m *models.OutboundMessage) (*models.ChatHistory, error) {
+	if messageProvider == nil {
+		err := errors.New("WhatsApp provider configuration missing")
+		markOutboundFailure(m, err)
+		return nil, err
+	}
+	// Consent may have been revoked after the row was queued. A failed
+	// lookup counts as a failed attempt: the row is retried, not sent.
+	optedOut, err := isOptedOut(m.Recipient)
+	if err != nil {
+		err = fmt.Errorf("consent lookup: %w", err)
+		markOutboundFailure(m, err)
+		return nil, err
+	}
+	if optedOut {
+		cancelOutbound(m, errOptedOut.Error())
+		return nil, errOptedOut
+	}
+	// A free-form row checked when it was queued may have waited (schedule,
+	// retries) past the end of the 24h window; the provider would reject it.
+	if m.TemplateID == nil {
+		if err := checkSessionWindow(m.SessionID, time.Now().UTC()); err != nil {
+			cancelOutbound(m, err.Error())
+			return nil, err
+		}
+	}
+	// Template rows are held back outside business hours or once the
+	// recipient hit the proactive limit.
+	if at, reason := checkSendPolicy(m.Recipient, m.TemplateID != nil, time.Now().UTC()); reason != "" {
+		deferOutbound(m, at, reason)
+		return nil, errDeferred
+	}
+
+	send := messaging.OutboundMessage{
+		To:    m.Recipient,
+		Body:  m.Body,
+		Media: outboundMedia(m),
+	}
+	kwargs := map[string]interface{}{}
+	if m.TemplateID != nil {
+		var tpl models.MessageTemplate
+		if err := db.DB.First(&tpl, *m.TemplateID).Error; err != nil {
+			markOutboundFailure(m, fmt.Errorf("template %d: %w", *m.TemplateID, err))
+			return nil, err
+		}
+		var params map[string]string
+		_ = json.Unmarshal(m.TemplateParams, &params)
+		body, t, err := providerTemplate(&tpl, params)
+		if err != nil {
+			markOutboundFailure(m, err)
+			return nil, err
+		}
+		send.Body = body
+		send.Template = t
+		kwargs["template"] = tpl.Name
+		kwargs["template_language"] = tpl.Language
+	}
+
+	result, failure, err := outboxRetry.Attempt(ctx, messageProvider, send, m.Attempts, m.MaxAttempts, time.Now().UTC())
+	if err != nil {
+		applyOutboundFailure(m, failure, err)
+		return nil, err
+	}
+
+	var vars map[string]interface{}
+	_ = json.Unmarshal(m.Vars, &vars)
+
+	now := time.Now().UTC()
+	history := models.ChatHistory{
+		SessionID: m.SessionID,
+		Message: buildAIMessage(aiMessage{
+			Message:    send.Body,
+			Vars:       vars,
+			Kwargs:     kwargs,
+			Attachment: send.Media,
+			Sender:     outboundSender(m),
+		}),
+		CreatedAt: now,
+	}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if m.CampaignID != nil {
+			if err := recordCampaignSend(tx, m, now); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&history).Error; err != nil {
+			return err
+		}
+		if err := recordDelivery(tx, result, m.SessionID, m.Recipient, &history.ID); err != nil {
+			return err
+		}
+		return tx.Model(m).Updates(map[string]interface{}{
+			"status":              models.OutboundSent,
+			"attempts":            m.Attempts + 1,
+			"provider":            result.Provider,
+			"provider_message_id": result.ProviderMessageID,
+			"chat_history_id":     history.ID,
+			"sent_at":             now,
+			"last_error":          "",
+		}).Error
+	})
+	if err != nil {
+		// The provider already has the message; keep it out of the retry loop.
+		log.Printf("❌ Outbound %d sent but not recorded: %v", m.ID, err)
+		m.Status = models.OutboundDead
+		m.LastError = fmt.Sprintf("sent as %s but history write failed: %v", result.ProviderMessageID, err)
+		db.DB.Model(m).Updates(map[string]interface{}{
+			"status":     m.Status,
+			"last_error": m.LastError,
+		})
+		if m.CampaignID != nil {
+			recordCampaignFailure(m)
+		}
+		return nil, err
+	}
+
+	m.Status = models.OutboundSent
+	m.Attempts++
+	m.Provider = result.Provider
+	m.ProviderMessageID = result.ProviderMessageID
+	m.ChatHistoryID = &history.ID
+	m.SentAt = &now
+	m.LastError = ""
+
+	return &history, nil
+}
+
+// outboundMedia decodes the attachment of an outbox row, if any.
+func outboundMedia(m *models.OutboundMessage) *messaging.Media {
+	if len(m.Media) == 0 || string(m.Media) == "null" {
+		return nil
+	}
+	var media messaging.Media
+	if err := json.Unmarshal(m.Media, &media); err != nil || media.URL == "" {
+		return nil
+	}
+	return &media
+}
+
+// deferOutbound puts a claimed row back in the queue without counting an
+// attempt.
+func deferOutbound(m *models.OutboundMessage, at time.Time, reason string) {
+	m.Status = models.OutboundPending
+	m.NextAttemptAt = at
+	m.LastError = "deferred: " + reason
+	if err := db.DB.Model(m).Updates(map[string]interface{}{
+		"status":          m.Status,
+		"next_attempt_at": m.NextAttemptAt,
+		"last_error":      m.LastError,
+	}).Error; err != nil {
+		log.Printf("❌ Failed to update outbound %d: %v", m.ID, err)
+	}
+}
+
+// cancelOutbound takes a claimed row out of the queue without sending it.
+func cancelOutbound(m *models.OutboundMessage, reason string) {
+	m.Status = models.OutboundCancelled
+	m.LastError = reason
+	if err := db.DB.Model(m).Updates(map[string]interface{}{
+		"status":     m.Status,
+		"last_error": m.LastError,
+	}).Error; err != nil {
+		log.Printf("❌ Failed to update outbound %d: %v", m.ID, err)
+	}
+	if m.CampaignID != nil {
+		db.DB.Model(&models.CampaignRecipient{}).
+			Where("campaign_id = ? AND outbound_message_id = ?", *m.CampaignID, m.ID).
+			Updates(map[string]interface{}{"status": models.RecipientSkipped, "error": reason})
+	}
+}
+
+// markOutboundFailure records a failed attempt and schedules the next one.
+func markOutboundFailure(m *models.OutboundMessage, cause error) {
+	applyOutboundFailure(m, outboxRetry.Fail(m.Attempts, m.MaxAttempts, cause, time.Now().UTC()), cause)
+}
+
+// applyOutboundFailure stores the state outboxRetry gave a failed row.
+func applyOutboundFailure(m *models.OutboundMessage, f outbox.Failure, cause error) {
+	m.Attempts = f.Attempts
+	m.LastError = cause.Error()
+	if f.Dead {
+		m.Status = models.OutboundDead
+	} else {
+		m.Status = models.OutboundPending
+		m.NextAttemptAt = f.NextAttemptAt
+	}
+
+	if err := db.DB.Model(m).Updates(map[string]interface{}{
+		"status":          m.Status,
+		"attempts":        m.Attempts,
+		"last_error":      m.LastError,
+		"next_attempt_at": m.NextAttemptAt,
+	}).Error; err != nil {
+		log.Printf("❌ Failed to update outbound %d: %v", m.ID, err)
+	}
+	if m.Status == models.OutboundDead && m.CampaignID != nil {
+		recordCampaignFailure(m)
+	}
+}
+
+// StartOutboxWorker delivers due outbox rows until ctx is cancelled.
+func StartOutboxWorker(ctx context.Context) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			processDueOutbound(ctx)
+		}
+	}
+}
+
+func processDueOutbound(ctx context.Context) {
+	// Rows left in "sending" by a crashed replica go back to the queue.
+	db.DB.Model(&models.OutboundMessage{}).
+		Where("status = ? AND updated_at < ?", models.OutboundSending, time.Now().UTC().Add(-outboxStaleSending)).
+		Update("status", models.OutboundPending)
+
+	var due []models.OutboundMessage
+	if err := db.DB.
+		Where("status = ? AND next_attempt_at <= ?", models.OutboundPending, time.Now().UTC()).
+		Order("next_attempt_at asc").
+		Limit(outboxBatchSize).
+		Find(&due).Error; err != nil {
+		log.Printf("❌ Failed to load outbox: %v", err)
+		return
+	}
+
+	for i := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		if !claimOutbound(due[i].ID) {
+			continue
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, 20*time.Second)
+		_, _ = dispatchOutbound(sendCtx, &due[i])
+		cancel()
+	}
+}
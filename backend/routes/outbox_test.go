@@ -0,0 +1,236 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/internal/messaging"
+	"bestdoctors_service/models"
+)
+
+const testPhone = "+5541999998888"
+
+// useFakeProvider makes the outbox send through a FakeProvider for the
+// duration of the test.
+func useFakeProvider(t *testing.T) *messaging.FakeProvider {
+	t.Helper()
+	prev := messageProvider
+	fake := messaging.NewFakeProvider()
+	messageProvider = fake
+	t.Cleanup(func() { messageProvider = prev })
+	return fake
+}
+
+// seedSession creates a session whose lead last wrote at lastHuman.
+func seedSession(t *testing.T, sid string, lastHuman time.Time) {
+	t.Helper()
+	if err := db.DB.Create(&models.SessionPhone{
+		SessionID:     sid,
+		Phone:         testPhone,
+		AIActive:      true,
+		CreatedAt:     lastHuman,
+		LastMessageAt: lastHuman,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DB.Create(&models.ChatHistory{
+		SessionID: sid,
+		Message:   `{"type":"human","content":"Oi"}`,
+		CreatedAt: lastHuman,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// claimedOutbound stores m as a row a worker has just claimed.
+func claimedOutbound(t *testing.T, m models.OutboundMessage) *models.OutboundMessage {
+	t.Helper()
+	m.Recipient = testPhone
+	m.Status = models.OutboundSending
+	m.MaxAttempts = outboxDefaultRetries
+	m.NextAttemptAt = time.Now().UTC()
+	if err := db.DB.Create(&m).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &m
+}
+
+func reloadOutbound(t *testing.T, id uint) models.OutboundMessage {
+	t.Helper()
+	var m models.OutboundMessage
+	if err := db.DB.First(&m, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestDispatchOutboundSent(t *testing.T) {
+	openTestDB(t)
+	fake := useFakeProvider(t)
+	seedSession(t, "s1", time.Now().UTC().Add(-time.Hour))
+	m := claimedOutbound(t, models.OutboundMessage{SessionID: "s1", Body: "Olá"})
+
+	history, err := dispatchOutbound(context.Background(), m)
+	if err != nil {
+		t.Fatalf("dispatchOutbound: %v", err)
+	}
+	if len(fake.Sent()) != 1 {
+		t.Fatalf("provider got %d messages, want 1", len(fake.Sent()))
+	}
+	got := reloadOutbound(t, m.ID)
+	if got.Status != models.OutboundSent || got.Attempts != 1 || got.ChatHistoryID == nil || *got.ChatHistoryID != history.ID {
+		t.Errorf("row = %+v, want sent with history %d", got, history.ID)
+	}
+	var d models.MessageDelivery
+	if err := db.DB.First(&d, "provider_message_id = ?", got.ProviderMessageID).Error; err != nil {
+		t.Fatalf("delivery not recorded: %v", err)
+	}
+	if d.ChatHistoryID == nil || *d.ChatHistoryID != history.ID {
+		t.Errorf("delivery = %+v, want history %d", d, history.ID)
+	}
+}
+
+// Each re-check runs when the row is dispatched, not only when it was
+// queued, and keeps the message from reaching the provider.
+func TestDispatchOutboundRechecks(t *testing.T) {
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Weekday()
+
+	tests := []struct {
+		name        string
+		lastHuman   time.Duration
+		template    bool
+		setup       func(t *testing.T)
+		err         error
+		status      string
+		lastError   string
+		rescheduled bool
+	}{
+		{
+			name:      "opted out",
+			lastHuman: time.Hour,
+			setup: func(t *testing.T) {
+				if err := db.DB.Create(&models.ContactConsent{Phone: "5541999998888", Status: models.ConsentOptedOut}).Error; err != nil {
+					t.Fatal(err)
+				}
+			},
+			err:       errOptedOut,
+			status:    models.OutboundCancelled,
+			lastError: errOptedOut.Error(),
+		},
+		{
+			name:      "window closed",
+			lastHuman: 25 * time.Hour,
+			err:       errOutsideWindow,
+			status:    models.OutboundCancelled,
+			lastError: errOutsideWindow.Error(),
+		},
+		{
+			name:      "template outside business hours",
+			lastHuman: 25 * time.Hour,
+			template:  true,
+			setup: func(t *testing.T) {
+				if err := db.DB.Save(&models.SendPolicy{
+					ID:                   1,
+					Timezone:             "UTC",
+					BusinessStart:        "00:00",
+					BusinessEnd:          "24:00",
+					BusinessDays:         strconv.Itoa(int(tomorrow)),
+					MaxProactive:         3,
+					ProactiveWindowHours: 24,
+				}).Error; err != nil {
+					t.Fatal(err)
+				}
+			},
+			err:         errDeferred,
+			status:      models.OutboundPending,
+			lastError:   "deferred: " + deferQuietHours,
+			rescheduled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openTestDB(t)
+			fake := useFakeProvider(t)
+			seedSession(t, "s1", time.Now().UTC().Add(-tt.lastHuman))
+			if tt.setup != nil {
+				tt.setup(t)
+			}
+			m := models.OutboundMessage{SessionID: "s1", Body: "Olá"}
+			if tt.template {
+				tpl := models.MessageTemplate{Name: "lembrete", Body: "Olá"}
+				if err := db.DB.Create(&tpl).Error; err != nil {
+					t.Fatal(err)
+				}
+				m.TemplateID = &tpl.ID
+			}
+			row := claimedOutbound(t, m)
+
+			if _, err := dispatchOutbound(context.Background(), row); !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if len(fake.Sent()) != 0 {
+				t.Errorf("provider got %d messages", len(fake.Sent()))
+			}
+			got := reloadOutbound(t, row.ID)
+			if got.Status != tt.status || got.LastError != tt.lastError || got.Attempts != 0 {
+				t.Errorf("row = %s %q after %d attempts, want %s %q", got.Status, got.LastError, got.Attempts, tt.status, tt.lastError)
+			}
+			if tt.rescheduled && !got.NextAttemptAt.After(time.Now().UTC()) {
+				t.Errorf("next attempt at %s, want a later business day", got.NextAttemptAt)
+			}
+		})
+	}
+}
+
+// A failed consent lookup is a failed attempt: the row is retried later.
+func TestDispatchOutboundConsentLookupFails(t *testing.T) {
+	openTestDB(t)
+	fake := useFakeProvider(t)
+	seedSession(t, "s1", time.Now().UTC().Add(-time.Hour))
+	m := claimedOutbound(t, models.OutboundMessage{SessionID: "s1", Body: "Olá"})
+	if err := db.DB.Exec("ALTER TABLE contact_consents RENAME TO contact_consents_gone").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := dispatchOutbound(context.Background(), m); err == nil || !strings.HasPrefix(err.Error(), "consent lookup") {
+		t.Fatalf("err = %v, want a consent lookup error", err)
+	}
+	if len(fake.Sent()) != 0 {
+		t.Errorf("provider got %d messages", len(fake.Sent()))
+	}
+	got := reloadOutbound(t, m.ID)
+	if got.Status != models.OutboundPending || got.Attempts != 1 || !got.NextAttemptAt.After(time.Now().UTC()) {
+		t.Errorf("row = %+v, want pending for a retry", got)
+	}
+}
+
+// Rows a crashed replica left in "sending" go back to the queue once stale;
+// rows another worker is sending right now are left alone.
+func TestProcessDueOutboundResetsStaleSending(t *testing.T) {
+	openTestDB(t)
+	fake := useFakeProvider(t)
+	seedSession(t, "s1", time.Now().UTC().Add(-time.Hour))
+	stale := claimedOutbound(t, models.OutboundMessage{SessionID: "s1", Body: "stale"})
+	busy := claimedOutbound(t, models.OutboundMessage{SessionID: "s1", Body: "busy"})
+	if err := db.DB.Model(stale).UpdateColumn("updated_at", time.Now().UTC().Add(-2*outboxStaleSending)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	processDueOutbound(context.Background())
+
+	if got := reloadOutbound(t, stale.ID); got.Status != models.OutboundSent {
+		t.Errorf("stale row is %s, want sent", got.Status)
+	}
+	if got := reloadOutbound(t, busy.ID); got.Status != models.OutboundSending {
+		t.Errorf("busy row is %s, want sending", got.Status)
+	}
+	if sent := fake.Sent(); len(sent) != 1 || sent[0].Body != "stale" {
+		t.Errorf("provider got %+v, want only the stale row", sent)
+	}
+}
@@ -0,0 +1,524 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/internal/session"
+	"bestdoctors_service/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	pipelineAutoInterval = 5 * time.Minute
+	maxPipelineMove      = 500
+	maxLossReasonLength  = 500
+	maxPipelineListed    = 200
+	maxPipelinePeriods   = 400
+)
+
+// PipelineMoveRequest moves sessions to a stage. EstimatedValue, when set,
+// replaces the sessions' value; LossReason is required for "perdido".
+type PipelineMoveRequest struct {
+	SessionIDs     []string `json:"session_ids"`
+	Stage          string   `json:"stage"`
+	LossReason     string   `json:"loss_reason"`
+	EstimatedValue *float64 `json:"estimated_value"`
+}
+
+type PipelineStageSummary struct {
+	Stage string  `json:"stage"`
+	Count int64   `json:"count"`
+	Value float64 `json:"value"`
+}
+
+type PipelineDetail struct {
+	models.SessionPipeline
+	Changes []models.PipelineStageChange `json:"changes"`
+}
+
+// PipelineSession is a session as listed on a pipeline column.
+type PipelineSession struct {
+	models.SessionPipeline
+	Phone         string    `json:"phone"`
+	LeadName      string    `json:"lead_name"`
+	LastMessageAt time.Time `json:"last_message_at"`
+}
+
+// pipelineMove is one stage change, manual or automatic.
+type pipelineMove struct {
+	Stage          string
+	EstimatedValue *float64
+	LossReason     string
+	Source         string
+}
+
+func validPipelineStage(stage string) bool {
+	for _, s := range models.PipelineStages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// pipelineRank orders stages for automatic moves, which only go forward.
+func pipelineRank(stage string) int {
+	switch stage {
+	case models.PipelineQualificado:
+		return 1
+	case models.PipelineProposta:
+		return 2
+	case models.PipelineFechado, models.PipelinePerdido:
+		return 3
+	}
+	return 0
+}
+
+func sameValue(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// moveSessionPipeline applies m to the session's pipeline row, creating it as
+// "novo" on first use, and records the change. Automatic moves never go
+// backwards and never touch a closed (fechado/perdido) session. changed is
+// false when nothing was different.
+func moveSessionPipeline(tx *gorm.DB, sid string, m pipelineMove, actor *session.SessionData) (p models.SessionPipeline, changed bool, err error) {
+	if err := tx.Exec(`
+		INSERT INTO session_pipeline (session_id, stage, stage_changed_at)
+		SELECT session_id, ?, created_at FROM session_phones WHERE session_id = ?
+		ON CONFLICT (session_id) DO NOTHING`, models.PipelineNovo, sid).Error; err != nil {
+		return p, false, err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&p, "session_id = ?", sid).Error; err != nil {
+		return p, false, err
+	}
+
+	from := p.Stage
+	if m.Source == models.PipelineSourceAuto && (pipelineRank(m.Stage) <= pipelineRank(from) || pipelineRank(from) == 3) {
+		return p, false, nil
+	}
+
+	value := p.EstimatedValue
+	if m.EstimatedValue != nil {
+		value = m.EstimatedValue
+	}
+	lossReason := ""
+	if m.Stage == models.PipelinePerdido {
+		lossReason = p.LossReason
+		if m.LossReason != "" {
+			lossReason = m.LossReason
+		}
+	}
+	stageChanged := from != m.Stage
+	if !stageChanged && sameValue(value, p.EstimatedValue) && lossReason == p.LossReason {
+		return p, false, nil
+	}
+
+	now := time.Now().UTC()
+	if stageChanged {
+		p.StageChangedAt = now
+	}
+	p.Stage = m.Stage
+	p.EstimatedValue = value
+	p.LossReason = lossReason
+	p.UpdatedBy = nil
+	p.UpdatedUsername = ""
+	if actor != nil {
+		if actor.UserID > 0 {
+			id := actor.UserID
+			p.UpdatedBy = &id
+		}
+		p.UpdatedUsername = actor.Username
+	}
+	if err := tx.Save(&p).Error; err != nil {
+		return p, false, err
+	}
+
+	change := models.PipelineStageChange{
+		SessionID:      sid,
+		FromStage:      from,
+		ToStage:        p.Stage,
+		EstimatedValue: p.EstimatedValue,
+		LossReason:     p.LossReason,
+		Source:         m.Source,
+		UserID:         p.UpdatedBy,
+		Username:       p.UpdatedUsername,
+		CreatedAt:      now,
+	}
+	if err := tx.Create(&change).Error; err != nil {
+		return p, false, err
+	}
+	if stageChanged {
+		if err := recordSessionEvent(tx, models.SessionEvent{
+			SessionID: sid,
+			EventType: models.SessionEventPipeline,
+			OldValue:  from,
+			NewValue:  p.Stage,
+			Reason:    p.LossReason,
+			CreatedAt: now,
+		}, actor); err != nil {
+			return p, false, err
+		}
+	}
+	return p, true, nil
+}
+
+// PipelineHandler handles GET /pipeline
+// ?session_id= returns the session's stage and its change history;
+// ?stage= lists the sessions in that stage; otherwise count and estimated
+// value per stage. Tag filters (?tag=) apply to the list and the summary.
+func PipelineHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	f := metricsFilterFromQuery(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	if sid := q.Get("session_id"); sid != "" {
+		writePipelineDetail(w, sid)
+		return
+	}
+	if stage := q.Get("stage"); stage != "" {
+		if !validPipelineStage(stage) {
+			http.Error(w, "invalid stage", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(pipelineSessions(stage, f.Tags))
+		return
+	}
+	json.NewEncoder(w).Encode(pipelineSummary(f.Tags))
+}
+
+// pipelineQuery selects session_phones (sp) joined to their pipeline row (p).
+func pipelineQuery(tags []string) *gorm.DB {
+	q := db.DB.Table("session_phones sp").
+		Joins("LEFT JOIN session_pipeline p ON p.session_id = sp.session_id")
+	if len(tags) > 0 {
+		q = q.Where("sp.session_id IN (?)", whereSessionTags(db.DB.Table("session_phones").Select("session_id"), tags))
+	}
+	return q
+}
+
+func pipelineSummary(tags []string) []PipelineStageSummary {
+	var rows []PipelineStageSummary
+	pipelineQuery(tags).
+		Select("COALESCE(p.stage, ?) AS stage, COUNT(*) AS count, COALESCE(SUM(p.estimated_value), 0) AS value", models.PipelineNovo).
+		Group("1").
+		Scan(&rows)
+
+	byStage := make(map[string]PipelineStageSummary, len(rows))
+	for _, row := range rows {
+		byStage[row.Stage] = row
+	}
+	out := make([]PipelineStageSummary, 0, len(models.PipelineStages))
+	for _, stage := range models.PipelineStages {
+		row := byStage[stage]
+		row.Stage = stage
+		out = append(out, row)
+	}
+	return out
+}
+
+func pipelineSessions(stage string, tags []string) []PipelineSession {
+	out := []PipelineSession{}
+	q := pipelineQuery(tags).
+		Select(`sp.session_id, COALESCE(p.stage, ?) AS stage, p.estimated_value, p.loss_reason,
+			COALESCE(p.stage_changed_at, sp.created_at) AS stage_changed_at, p.updated_by, p.updated_username,
+			p.created_at, p.updated_at, sp.phone, sp.lead_name, sp.last_message_at`, models.PipelineNovo)
+	if stage == models.PipelineNovo {
+		q = q.Where("p.stage IS NULL OR p.stage = ?", stage)
+	} else {
+		q = q.Where("p.stage = ?", stage)
+	}
+	q.Order("sp.last_message_at DESC").Limit(maxPipelineListed).Scan(&out)
+	return out
+}
+
+func writePipelineDetail(w http.ResponseWriter, sid string) {
+	var s models.SessionPhone
+	if err := db.DB.First(&s, "session_id = ?", sid).Error; err != nil {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	detail := PipelineDetail{Changes: []models.PipelineStageChange{}}
+	if err := db.DB.First(&detail.SessionPipeline, "session_id = ?", sid).Error; err != nil {
+		detail.SessionPipeline = models.SessionPipeline{
+			SessionID:      sid,
+			Stage:          models.PipelineNovo,
+			StageChangedAt: s.CreatedAt,
+		}
+	}
+	db.DB.Where("session_id = ?", sid).Order("created_at asc, id asc").Find(&detail.Changes)
+	json.NewEncoder(w).Encode(detail)
+}
+
+// PipelineMoveHandler handles POST /pipeline/move
+// Moves every listed session to the stage in one transaction. Unknown
+// sessions are reported back and skipped.
+func PipelineMoveHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req PipelineMoveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.SessionIDs) == 0 || req.Stage == "" {
+		http.Error(w, "session_ids and stage are required", http.StatusBadRequest)
+		return
+	}
+	req.LossReason = strings.TrimSpace(req.LossReason)
+	switch {
+	case len(req.SessionIDs) > maxPipelineMove:
+		http.Error(w, fmt.Sprintf("at most %d sessions per move", maxPipelineMove), http.StatusBadRequest)
+		return
+	case !validPipelineStage(req.Stage):
+		http.Error(w, "invalid stage", http.StatusBadRequest)
+		return
+	case req.Stage == models.PipelinePerdido && req.LossReason == "":
+		http.Error(w, "loss_reason is required when moving to perdido", http.StatusBadRequest)
+		return
+	case len(req.LossReason) > maxLossReasonLength:
+		http.Error(w, "loss_reason is too long", http.StatusBadRequest)
+		return
+	case req.EstimatedValue != nil && (*req.EstimatedValue < 0 || *req.EstimatedValue >= 1e10):
+		http.Error(w, "estimated_value must be between 0 and 9999999999.99", http.StatusBadRequest)
+		return
+	}
+
+	actor := sessionUser(r)
+	move := pipelineMove{
+		Stage:          req.Stage,
+		EstimatedValue: req.EstimatedValue,
+		LossReason:     req.LossReason,
+		Source:         models.PipelineSourceManual,
+	}
+	moved := []models.SessionPipeline{}
+	notFound := []string{}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		for _, sid := range req.SessionIDs {
+			p, changed, err := moveSessionPipeline(tx, sid, move, actor)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				notFound = append(notFound, sid)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if changed {
+				moved = append(moved, p)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "failed to move sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"moved":     moved,
+		"not_found": notFound,
+	})
+}
+
+// StartPipelineWorker advances recently active sessions from what the bot
+// has collected so far, until ctx is cancelled.
+func StartPipelineWorker(ctx context.Context) {
+	ticker := time.NewTicker(pipelineAutoInterval)
+	defer ticker.Stop()
+
+	since := time.Now().UTC().Add(-24 * time.Hour)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now().UTC()
+			advancePipelineStages(since)
+			since = now.Add(-pipelineAutoInterval)
+		}
+	}
+}
+
+// autoPipelineStage maps the bot flow to a stage from the session's deepest
+// flow state (see detectFlowState): a lead that told us the specialty is
+// qualified; one that finished the flow is waiting on a proposal. Closing a
+// deal is always manual.
+func autoPipelineStage(flowState int) string {
+	switch {
+	case flowState >= 5:
+		return models.PipelineProposta
+	case flowState >= 2:
+		return models.PipelineQualificado
+	}
+	return ""
+}
+
+// advancePipelineStages moves sessions active since since forward from
+// session_phones.flow_state. StartFlowStateWorker fills that column within
+// a minute of a message; the worker's overlapping windows cover the lag.
+func advancePipelineStages(since time.Time) {
+	var rows []struct {
+		SessionID string
+		FlowState int
+	}
+	if err := db.DB.Table("session_phones sp").
+		Select("sp.session_id, sp.flow_state").
+		Joins("LEFT JOIN session_pipeline p ON p.session_id = sp.session_id").
+		Where("sp.last_message_at >= ? AND sp.flow_state >= 2", since).
+		Where("p.stage IS NULL OR p.stage IN ?", []string{models.PipelineNovo, models.PipelineQualificado}).
+		Scan(&rows).Error; err != nil {
+		log.Printf("❌ Failed to load sessions for pipeline: %v", err)
+		return
+	}
+
+	for _, row := range rows {
+		stage := autoPipelineStage(row.FlowState)
+		if stage == "" {
+			continue
+		}
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			_, _, err := moveSessionPipeline(tx, row.SessionID, pipelineMove{Stage: stage, Source: models.PipelineSourceAuto}, systemActor)
+			return err
+		})
+		if err != nil {
+			log.Printf("❌ Failed to advance pipeline for %s: %v", row.SessionID, err)
+		}
+	}
+}
+
+//
+// ──────────────── Relatório de pipeline ────────────────
+//
+
+// PipelineReport is the pipeline as it stood at the end of each period:
+// how many sessions sat in each stage and their summed estimated value.
+type PipelineReport struct {
+	Interval string           `json:"interval"`
+	From     time.Time        `json:"from"`
+	To       time.Time        `json:"to"`
+	Periods  []PipelinePeriod `json:"periods"`
+}
+
+type PipelinePeriod struct {
+	Start  time.Time              `json:"start"`
+	Stages []PipelineStageSummary `json:"stages"`
+}
+
+// pipelineReportRange reads interval (day, week or month; default week) and
+// from/to (default the last 30 days) from the report filters.
+func pipelineReportRange(filters map[string]interface{}) (interval string, from, to time.Time, err error) {
+	interval = "week"
+	if v, ok := filters["interval"].(string); ok && v != "" {
+		interval = v
+	}
+	// Same steps as the report's generate_series over '1 <interval>'.
+	var months, days int
+	switch interval {
+	case "day":
+		days = 1
+	case "week":
+		days = 7
+	case "month":
+		months = 1
+	default:
+		return "", from, to, errors.New("interval must be day, week or month")
+	}
+
+	to = time.Now().UTC()
+	from = to.AddDate(0, 0, -30)
+	f, t := parseTimeFilter(filters)
+	if t != nil {
+		to = t.UTC()
+		from = to.AddDate(0, 0, -30)
+	}
+	if f != nil {
+		from = f.UTC()
+	}
+	if !from.Before(to) {
+		return "", from, to, errors.New("from must be before to")
+	}
+	periods := 0
+	for start := from; !start.After(to); start = start.AddDate(0, months, days) {
+		if periods++; periods > maxPipelinePeriods {
+			return "", from, to, fmt.Errorf("range covers more than %d periods", maxPipelinePeriods)
+		}
+	}
+	return interval, from, to, nil
+}
+
+// CalculatePipelineReport rebuilds each period's snapshot from
+// pipeline_stage_changes: a session counts in the stage of its last change
+// before the period ended, or as "novo" if it existed but had none.
+func CalculatePipelineReport(interval string, from, to time.Time, tags []string) (PipelineReport, error) {
+	report := PipelineReport{Interval: interval, From: from, To: to, Periods: []PipelinePeriod{}}
+
+	scope := ""
+	args := []interface{}{"1 " + interval, interval, from, to, "1 " + interval, models.PipelineNovo}
+	if len(tags) > 0 {
+		scope = "WHERE sp.session_id IN (?)"
+		args = append(args, whereSessionTags(db.DB.Table("session_phones").Select("session_id"), tags))
+	}
+
+	var rows []struct {
+		Start time.Time
+		PipelineStageSummary
+	}
+	err := db.DB.Raw(`
+		WITH periods AS (
+			SELECT gs AS start, gs + ?::interval AS stop
+			FROM generate_series(date_trunc(?, ?::timestamp), ?::timestamp, ?::interval) gs
+		)
+		SELECT pr.start, COALESCE(c.to_stage, ?) AS stage, COUNT(*) AS count,
+		       COALESCE(SUM(c.estimated_value), 0) AS value
+		FROM periods pr
+		JOIN session_phones sp ON sp.created_at < pr.stop
+		LEFT JOIN LATERAL (
+			SELECT pc.to_stage, pc.estimated_value
+			FROM pipeline_stage_changes pc
+			WHERE pc.session_id = sp.session_id AND pc.created_at < pr.stop
+			ORDER BY pc.created_at DESC, pc.id DESC
+			LIMIT 1
+		) c ON true
+		`+scope+`
+		GROUP BY 1, 2
+		ORDER BY 1`, args...).Scan(&rows).Error
+	if err != nil {
+		return report, err
+	}
+
+	byPeriod := map[time.Time]map[string]PipelineStageSummary{}
+	var starts []time.Time
+	for _, row := range rows {
+		if byPeriod[row.Start] == nil {
+			byPeriod[row.Start] = map[string]PipelineStageSummary{}
+			starts = append(starts, row.Start)
+		}
+		byPeriod[row.Start][row.Stage] = row.PipelineStageSummary
+	}
+	for _, start := range starts {
+		period := PipelinePeriod{Start: start, Stages: make([]PipelineStageSummary, 0, len(models.PipelineStages))}
+		for _, stage := range models.PipelineStages {
+			s := byPeriod[start][stage]
+			s.Stage = stage
+			period.Stages = append(period.Stages, s)
+		}
+		report.Periods = append(report.Periods, period)
+	}
+	return report, nil
+}
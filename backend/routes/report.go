@@ -54,162 +54,164 @@ func getSessionDelta(since time.Time) []models.SessionPhone {
 
 // Sessions com faixa [from, to] aplicada em last_message_at
 func getSessionsWithRange(from, to *time.Time) []models.SessionPhone {
+	return getSessionsFiltered(MetricsFilter{From: from, To: to})
+}
+
+func getSessionsFiltered(f MetricsFilter) []models.SessionPhone {
 	var sessions []models.SessionPhone
-	tx := db.DB
-	if from != nil {
-		tx = tx.Where("last_message_at >= ?", *from)
+	f.sessionQuery(db.DB).Order("session_id").Find(&sessions)
+	return sessions
+}
+
+// MetricsFilter narrows the sessions a metric or report covers. From/To
+// apply to last_message_at; Tags keeps sessions carrying any of the tags;
+// ExcludeAttendant drops messages typed by panel users (see isAttendantTurn)
+// so only bot and lead turns are measured.
+type MetricsFilter struct {
+	From             *time.Time
+	To               *time.Time
+	Tags             []string
+	ExcludeAttendant bool
+}
+
+// metricsFilterFromQuery reads ?tag=/?tags= and ?exclude_attendant=true.
+func metricsFilterFromQuery(r *http.Request) MetricsFilter {
+	return MetricsFilter{
+		Tags:             tagsFromQuery(r),
+		ExcludeAttendant: r.URL.Query().Get("exclude_attendant") == "true",
 	}
-	if to != nil {
-		tx = tx.Where("last_message_at <= ?", *to)
+}
+
+// metricsFilterFromReport reads the report filters: from, to, tags and
+// exclude_attendant.
+func metricsFilterFromReport(filters map[string]interface{}) MetricsFilter {
+	from, to := parseTimeFilter(filters)
+	f := MetricsFilter{From: from, To: to, Tags: tagsFromFilters(filters)}
+	if v, ok := filters["exclude_attendant"].(bool); ok {
+		f.ExcludeAttendant = v
 	}
-	tx.Order("session_id").Find(&sessions)
-	return sessions
+	return f
+}
+
+// sessionQuery applies the filter to a query on session_phones.
+func (f MetricsFilter) sessionQuery(q *gorm.DB) *gorm.DB {
+	if f.From != nil {
+		q = q.Where("last_message_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		q = q.Where("last_message_at <= ?", *f.To)
+	}
+	return whereSessionTags(q, f.Tags)
+}
+
+// history drops attendant turns when the filter asks for it.
+func (f MetricsFilter) history(h []models.ChatHistory) []models.ChatHistory {
+	if f.ExcludeAttendant {
+		return withoutAttendantTurns(h)
+	}
+	return h
+}
+
+// cacheKey derives the metrics_cache key for base under this filter.
+func (f MetricsFilter) cacheKey(base string) string {
+	key := base
+	if f.ExcludeAttendant {
+		key += ":no_attendant"
+	}
+	if len(f.Tags) > 0 {
+		key += ":tags=" + strings.Join(f.Tags, ",")
+	}
+	return key
+}
+
+// factsQuery selects the sessions under the filter (sessions narrowed
+// further by the caller, if needed) as sp, joined with their
+// session_metric_facts row as mf. Sessions the worker has not seen yet
+// come with NULL facts.
+func (f MetricsFilter) factsQuery(q, sessions *gorm.DB) *gorm.DB {
+	sp := f.sessionQuery(sessions).Select("session_id, contact_id")
+	return q.Table("(?) AS sp", sp).
+		Joins("LEFT JOIN session_metric_facts mf ON mf.session_id = sp.session_id")
+}
+
+// sessionContact is a filtered session with the contact it belongs to, so
+// metrics can count people as well as sessions.
+type sessionContact struct {
+	SessionID string
+	ContactID *uint
+}
+
+func (s sessionContact) key() string {
+	return contactKey(s.SessionID, s.ContactID)
 }
 
 //
 // ──────────────── Cálculo de métricas (com from/to) ────────────────
 //
-// IMPORTANTE: tipos/funções usadas aqui existem em outros arquivos do mesmo pacote:
-// - AbandonmentResponse, rawMessage, contentPayload           → abandonment.go
-// - FlowDepthResponse, flowRawMessage, flowContentPayload,
-//   detectFlowState                                           → flowdepth.go
-// - ReengagementResponse, recapRawMessage                     → reengagement.go
+// As métricas agregam session_metric_facts (ver metric_facts.go), mantida
+// pelo StartMetricFactsWorker. Tipos usados aqui:
+// - AbandonmentResponse                                       → abandonment.go
+// - FlowDepthResponse                                         → flowdepth.go
+// - ReengagementResponse                                      → reengagement.go
 //
 
-// Abandono com filtro por faixa de datas (em last_message_at)
-func CalculateAbandonmentMetricsFiltered(supabaseDB *gorm.DB, from, to *time.Time) (AbandonmentResponse, error) {
+// Abandono com filtro por faixa de datas (em last_message_at) e tags.
+// Sessões com menos de duas mensagens nunca contam como concluídas.
+func CalculateAbandonmentMetricsFiltered(supabaseDB *gorm.DB, f MetricsFilter) (AbandonmentResponse, error) {
 	dbNoPrep := supabaseDB.Session(&gorm.Session{PrepareStmt: false})
 
-	var sessionIDs []string
-	q := dbNoPrep.Table(models.SessionPhone{}.TableName())
-	if from != nil {
-		q = q.Where("last_message_at >= ?", *from)
-	}
-	if to != nil {
-		q = q.Where("last_message_at <= ?", *to)
-	}
-	db.RetryForever(100*time.Millisecond, func() error {
-		return q.Pluck("session_id", &sessionIDs).Error
-	})
-
-	totalSessions := int64(len(sessionIDs))
-	var completedSessions int64
-	var totalEngagedSessions int64
-
-	for _, sid := range sessionIDs {
-		var history []models.ChatHistory
-		db.RetryForever(50*time.Millisecond, func() error {
-			return dbNoPrep.
-				Where("session_id = ?", sid).
-				Order("created_at ASC").
-				Find(&history).Error
-		})
-
-		if len(history) < 2 {
-			continue
-		}
+	msgs, finalizar := "mf.message_count", "mf.finalizar"
+	if f.ExcludeAttendant {
+		msgs, finalizar = "mf.message_count - mf.attendant_count", "mf.bot_finalizar"
+	}
+	completed := msgs + " >= 2 AND " + finalizar
+	contact := "COALESCE('c' || sp.contact_id, 's' || sp.session_id)"
 
-		humanCount := 0
-		finalizeFlag := false
-		for i, entry := range history {
-			var rm rawMessage
-			if err := json.Unmarshal([]byte(entry.Message), &rm); err != nil {
-				continue
-			}
-			if rm.Type == "human" {
-				humanCount++
-			}
-			if i == len(history)-1 {
-				var cp contentPayload
-				if err := json.Unmarshal([]byte(rm.Content), &cp); err == nil {
-					finalizeFlag = cp.Output.Vars.Finalizar
-				}
-			}
-		}
-		if finalizeFlag {
-			completedSessions++
-		}
-		if humanCount > 1 {
-			totalEngagedSessions++
-		}
+	var resp AbandonmentResponse
+	if err := f.factsQuery(dbNoPrep, dbNoPrep.Table(models.SessionPhone{}.TableName())).
+		Select(`COUNT(*) AS total_sessions,
+			COUNT(*) FILTER (WHERE ` + completed + `) AS completed_sessions,
+			COUNT(*) FILTER (WHERE mf.human_count > 1) AS total_engaged_sessions,
+			COUNT(DISTINCT ` + contact + `) AS total_contacts,
+			COUNT(DISTINCT ` + contact + `) FILTER (WHERE ` + completed + `) AS completed_contacts`).
+		Scan(&resp).Error; err != nil {
+		return resp, err
 	}
 
-	abandonmentRate := 0.0
-	if totalSessions > 0 {
-		abandonmentRate = float64(totalSessions-completedSessions) / float64(totalSessions) * 100.0
+	if resp.TotalSessions > 0 {
+		resp.AbandonmentRate = float64(resp.TotalSessions-resp.CompletedSessions) / float64(resp.TotalSessions) * 100.0
 	}
-	engagedAbandonmentRate := 0.0
-	if totalEngagedSessions > 0 {
-		engagedAbandonmentRate = float64(totalEngagedSessions-completedSessions) / float64(totalEngagedSessions) * 100.0
+	if resp.TotalEngagedSessions > 0 {
+		resp.EngagedAbandonmentRate = float64(resp.TotalEngagedSessions-resp.CompletedSessions) / float64(resp.TotalEngagedSessions) * 100.0
 	}
-
-	return AbandonmentResponse{
-		TotalSessions:          totalSessions,
-		CompletedSessions:      completedSessions,
-		AbandonmentRate:        abandonmentRate,
-		TotalEngagedSessions:   totalEngagedSessions,
-		EngagedAbandonmentRate: engagedAbandonmentRate,
-	}, nil
+	return resp, nil
 }
 
 // Profundidade do fluxo com filtro por faixa [from, to] (em last_message_at)
-func CalculateFlowDepthMetricsFiltered(supabaseDB *gorm.DB, from, to *time.Time) (FlowDepthResponse, error) {
+func CalculateFlowDepthMetricsFiltered(supabaseDB *gorm.DB, f MetricsFilter) (FlowDepthResponse, error) {
 	dbNoPrep := supabaseDB.Session(&gorm.Session{PrepareStmt: false})
 
-	var sessionIDs []string
-	q := dbNoPrep.Table(models.SessionPhone{}.TableName())
-	if from != nil {
-		q = q.Where("last_message_at >= ?", *from)
+	state := "COALESCE(mf.max_flow_state, 0)"
+	if f.ExcludeAttendant {
+		state = "COALESCE(mf.bot_max_flow_state, 0)"
+	}
+	var rows []struct {
+		State int
+		Count int64
 	}
-	if to != nil {
-		q = q.Where("last_message_at <= ?", *to)
+	if err := f.factsQuery(dbNoPrep, dbNoPrep.Table(models.SessionPhone{}.TableName())).
+		Select(state + " AS state, COUNT(*) AS count").
+		Group(state).
+		Scan(&rows).Error; err != nil {
+		return FlowDepthResponse{}, err
 	}
-	db.RetryForever(100*time.Millisecond, func() error {
-		return q.Pluck("session_id", &sessionIDs).Error
-	})
 
-	total := int64(len(sessionIDs))
+	var total, sumDepth int64
 	depthCount := make(map[int]int64, 6)
-	var sumDepth int64
-
-	for _, sid := range sessionIDs {
-		var history []models.ChatHistory
-		db.RetryForever(50*time.Millisecond, func() error {
-			return dbNoPrep.
-				Where("session_id = ?", sid).
-				Order("created_at ASC").
-				Find(&history).Error
-		})
-
-		if len(history) == 0 {
-			depthCount[0]++
-			continue
-		}
-
-		maxState := 0
-		for _, entry := range history {
-			var rm flowRawMessage
-			if err := json.Unmarshal([]byte(entry.Message), &rm); err != nil {
-				continue
-			}
-			if rm.Type != "ai" {
-				continue
-			}
-			var cp flowContentPayload
-			if err := json.Unmarshal([]byte(rm.Content), &cp); err != nil {
-				continue
-			}
-			st := detectFlowState(cp)
-			if st > maxState {
-				maxState = st
-				if st == 5 {
-					break
-				}
-			}
-		}
-
-		depthCount[maxState]++
-		sumDepth += int64(maxState)
+	for _, r := range rows {
+		depthCount[r.State] = r.Count
+		total += r.Count
+		sumDepth += int64(r.State) * r.Count
 	}
 
 	distributionPercent := make(map[int]float64, len(depthCount))
@@ -242,86 +244,63 @@ func CalculateFlowDepthMetricsFiltered(supabaseDB *gorm.DB, from, to *time.Time)
 	}, nil
 }
 
-// Reengajamento com filtro por faixa [from, to] (em last_message_at)
-func CalculateReengagementMetricsFiltered(supabaseDB *gorm.DB, includeSessions bool, from, to *time.Time) (ReengagementResponse, error) {
+// Reengajamento com filtro por faixa [from, to] (em last_message_at).
+// Com campaignID != 0 considera apenas os destinatários da campanha e o
+// marcador de recaptura gravado por ela.
+func CalculateReengagementMetricsFiltered(supabaseDB *gorm.DB, includeSessions bool, f MetricsFilter, campaignID uint) (ReengagementResponse, error) {
 	dbNoPrep := supabaseDB.Session(&gorm.Session{PrepareStmt: false})
 
-	var sessionIDs []string
-	q := dbNoPrep.Table(models.SessionPhone{}.TableName())
-	if from != nil {
-		q = q.Where("last_message_at >= ?", *from)
+	sessions := dbNoPrep.Table(models.SessionPhone{}.TableName())
+	if campaignID != 0 {
+		sessions = sessions.Where("session_id IN (?)", dbNoPrep.Model(&models.CampaignRecipient{}).
+			Select("session_id").
+			Where("campaign_id = ? AND status = ?", campaignID, models.RecipientSent))
 	}
-	if to != nil {
-		q = q.Where("last_message_at <= ?", *to)
+	q := f.factsQuery(dbNoPrep, sessions)
+	reengaged := "mf.reengaged"
+	if campaignID != 0 {
+		q = q.Joins("JOIN session_metric_recaptures mr ON mr.session_id = sp.session_id AND mr.campaign_id = ?", campaignID)
+		reengaged = "mr.reengaged"
+	} else {
+		q = q.Where("mf.recapture_index IS NOT NULL")
 	}
-	db.RetryForever(100*time.Millisecond, func() error {
-		return q.Pluck("session_id", &sessionIDs).Error
-	})
-
-	recaptureSet := make(map[string]struct{})
-	reengagedSet := make(map[string]struct{})
+	q = q.Session(&gorm.Session{})
 
-	for _, sid := range sessionIDs {
-		var history []models.ChatHistory
-		db.RetryForever(50*time.Millisecond, func() error {
-			return dbNoPrep.
-				Where("session_id = ?", sid).
-				Order("created_at ASC").
-				Find(&history).Error
-		})
-
-		recaptureIdx := -1
-		for i, entry := range history {
-			var rm recapRawMessage
-			if err := json.Unmarshal([]byte(entry.Message), &rm); err != nil {
-				continue
-			}
-			if rm.Type == "human" && strings.HasPrefix(rm.Content, "Recapture - ") {
-				recaptureIdx = i
-				break
-			}
-		}
-		if recaptureIdx < 0 {
-			continue
-		}
-		recaptureSet[sid] = struct{}{}
-
-		for j := recaptureIdx + 1; j < len(history); j++ {
-			var rm recapRawMessage
-			if err := json.Unmarshal([]byte(history[j].Message), &rm); err != nil {
-				continue
-			}
-			if rm.Type == "human" && !strings.HasPrefix(rm.Content, "Recapture - ") {
-				reengagedSet[sid] = struct{}{}
-				break
-			}
-		}
+	var counts struct {
+		Recaptured int64
+		Reengaged  int64
 	}
-
-	recaptureSessionIDs := make([]string, 0, len(recaptureSet))
-	for sid := range recaptureSet {
-		recaptureSessionIDs = append(recaptureSessionIDs, sid)
+	if err := q.
+		Select("COUNT(*) AS recaptured, COUNT(*) FILTER (WHERE " + reengaged + ") AS reengaged").
+		Scan(&counts).Error; err != nil {
+		return ReengagementResponse{}, err
 	}
-	reengagedSessionIDs := make([]string, 0, len(reengagedSet))
-	for sid := range reengagedSet {
-		reengagedSessionIDs = append(reengagedSessionIDs, sid)
+	resp := ReengagementResponse{
+		TotalRecaptureSessions: counts.Recaptured,
+		ReengagedSessions:      counts.Reengaged,
 	}
-
-	totalRecapture := int64(len(recaptureSessionIDs))
-	totalReengaged := int64(len(reengagedSessionIDs))
-	rate := 0.0
-	if totalRecapture > 0 {
-		rate = float64(totalReengaged) / float64(totalRecapture) * 100.0
+	if resp.TotalRecaptureSessions > 0 {
+		resp.ReengagementRate = float64(resp.ReengagedSessions) / float64(resp.TotalRecaptureSessions) * 100.0
 	}
 
-	resp := ReengagementResponse{
-		TotalRecaptureSessions: totalRecapture,
-		ReengagedSessions:      totalReengaged,
-		ReengagementRate:       rate,
-	}
 	if includeSessions {
-		resp.RecaptureSessionIDs = recaptureSessionIDs
-		resp.ReengagedSessionIDs = reengagedSessionIDs
+		var rows []struct {
+			SessionID string
+			Reengaged bool
+		}
+		if err := q.Select("sp.session_id, " + reengaged + " AS reengaged").
+			Order("sp.session_id").
+			Scan(&rows).Error; err != nil {
+			return resp, err
+		}
+		resp.RecaptureSessionIDs = make([]string, 0, len(rows))
+		resp.ReengagedSessionIDs = []string{}
+		for _, r := range rows {
+			resp.RecaptureSessionIDs = append(resp.RecaptureSessionIDs, r.SessionID)
+			if r.Reengaged {
+				resp.ReengagedSessionIDs = append(resp.ReengagedSessionIDs, r.SessionID)
+			}
+		}
 	}
 	return resp, nil
 }
@@ -331,7 +310,7 @@ func CalculateReengagementMetricsFiltered(supabaseDB *gorm.DB, includeSessions b
 //
 
 type ReportRequest struct {
-	Report  string                 `json:"report"` // "session" | "abandonment" | "flowDepth" | "reengagement"
+	Report  string                 `json:"report"` // "session" | "abandonment" | "flowDepth" | "reengagement" | "pipeline"
 	Type    string                 `json:"type"`   // "json" | "csv" | "pdf" | "xls" | "xlsx"
 	Filters map[string]interface{} `json:"filters"`
 }
@@ -348,7 +327,7 @@ func ReportHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	from, to := parseTimeFilter(req.Filters)
+	f := metricsFilterFromReport(req.Filters)
 
 	var (
 		result interface{}
@@ -357,13 +336,13 @@ func ReportHandler(w http.ResponseWriter, r *http.Request) {
 
 	switch req.Report {
 	case "session":
-		result = getSessionsWithRange(from, to)
+		result = getSessionsFiltered(f)
 
 	case "abandonment":
-		result, err = CalculateAbandonmentMetricsFiltered(db.SupabaseDB, from, to)
+		result, err = CalculateAbandonmentMetricsFiltered(db.SupabaseDB, f)
 
 	case "flowDepth":
-		result, err = CalculateFlowDepthMetricsFiltered(db.SupabaseDB, from, to)
+		result, err = CalculateFlowDepthMetricsFiltered(db.SupabaseDB, f)
 
 	case "reengagement":
 		include := false
@@ -372,7 +351,21 @@ func ReportHandler(w http.ResponseWriter, r *http.Request) {
 				include = v
 			}
 		}
-		result, err = CalculateReengagementMetricsFiltered(db.SupabaseDB, include, from, to)
+		var campaignID uint
+		if req.Filters != nil {
+			if v, ok := req.Filters["campaign_id"].(float64); ok && v > 0 {
+				campaignID = uint(v)
+			}
+		}
+		result, err = CalculateReengagementMetricsFiltered(db.SupabaseDB, include, f, campaignID)
+
+	case "pipeline":
+		interval, from, to, rangeErr := pipelineReportRange(req.Filters)
+		if rangeErr != nil {
+			http.Error(w, rangeErr.Error(), http.StatusBadRequest)
+			return
+		}
+		result, err = CalculatePipelineReport(interval, from, to, f.Tags)
 
 	default:
 		http.Error(w, "invalid report type", http.StatusBadRequest)
@@ -418,13 +411,15 @@ func writeAsCSV(w http.ResponseWriter, data interface{}, filename string) {
 
 	switch v := data.(type) {
 	case AbandonmentResponse:
-		_ = cw.Write([]string{"total_sessions", "completed_sessions", "abandonment_rate", "total_engaged_sessions", "engaged_abandonment_rate"})
+		_ = cw.Write([]string{"total_sessions", "completed_sessions", "abandonment_rate", "total_engaged_sessions", "engaged_abandonment_rate", "total_contacts", "completed_contacts"})
 		_ = cw.Write([]string{
 			fmt.Sprintf("%d", v.TotalSessions),
 			fmt.Sprintf("%d", v.CompletedSessions),
 			fmt.Sprintf("%.2f", v.AbandonmentRate),
 			fmt.Sprintf("%d", v.TotalEngagedSessions),
 			fmt.Sprintf("%.2f", v.EngagedAbandonmentRate),
+			fmt.Sprintf("%d", v.TotalContacts),
+			fmt.Sprintf("%d", v.CompletedContacts),
 		})
 
 	case FlowDepthResponse:
@@ -462,6 +457,19 @@ func writeAsCSV(w http.ResponseWriter, data interface{}, filename string) {
 			}
 		}
 
+	case PipelineReport:
+		_ = cw.Write([]string{"period_start", "stage", "count", "value"})
+		for _, p := range v.Periods {
+			for _, st := range p.Stages {
+				_ = cw.Write([]string{
+					p.Start.UTC().Format(time.RFC3339),
+					st.Stage,
+					fmt.Sprintf("%d", st.Count),
+					fmt.Sprintf("%.2f", st.Value),
+				})
+			}
+		}
+
 	case []models.SessionPhone:
 		_ = cw.Write([]string{
 			"session_id", "phone", "ai_active", "created_at", "last_message_at", "lead_name",
@@ -507,10 +515,10 @@ func writeAsXLSX(w http.ResponseWriter, data interface{}, filename string) {
 	switch v := data.(type) {
 	case AbandonmentResponse:
 		sheet := writeSheet("Abandonment")
-		_ = f.SetSheetRow(sheet, "A1", &[]interface{}{"total_sessions", "completed_sessions", "abandonment_rate", "total_engaged_sessions", "engaged_abandonment_rate"})
-		_ = f.SetSheetRow(sheet, "A2", &[]interface{}{v.TotalSessions, v.CompletedSessions, fmt.Sprintf("%.2f", v.AbandonmentRate), v.TotalEngagedSessions, fmt.Sprintf("%.2f", v.EngagedAbandonmentRate)})
-		_ = f.SetColWidth(sheet, "A", "E", 28)
-		_ = f.AutoFilter(sheet, "A1:E2", nil)
+		_ = f.SetSheetRow(sheet, "A1", &[]interface{}{"total_sessions", "completed_sessions", "abandonment_rate", "total_engaged_sessions", "engaged_abandonment_rate", "total_contacts", "completed_contacts"})
+		_ = f.SetSheetRow(sheet, "A2", &[]interface{}{v.TotalSessions, v.CompletedSessions, fmt.Sprintf("%.2f", v.AbandonmentRate), v.TotalEngagedSessions, fmt.Sprintf("%.2f", v.EngagedAbandonmentRate), v.TotalContacts, v.CompletedContacts})
+		_ = f.SetColWidth(sheet, "A", "G", 28)
+		_ = f.AutoFilter(sheet, "A1:G2", nil)
 
 	case FlowDepthResponse:
 		sheet := writeSheet("FlowDepth")
@@ -549,6 +557,19 @@ func writeAsXLSX(w http.ResponseWriter, data interface{}, filename string) {
 			_ = f.AutoFilter(s, fmt.Sprintf("A1:A%d", len(v.ReengagedSessionIDs)+1), nil)
 		}
 
+	case PipelineReport:
+		sheet := writeSheet("Pipeline")
+		_ = f.SetSheetRow(sheet, "A1", &[]interface{}{"period_start", "stage", "count", "value"})
+		row := 2
+		for _, p := range v.Periods {
+			for _, st := range p.Stages {
+				_ = f.SetSheetRow(sheet, fmt.Sprintf("A%d", row), &[]interface{}{p.Start.UTC().Format(time.RFC3339), st.Stage, st.Count, st.Value})
+				row++
+			}
+		}
+		_ = f.SetColWidth(sheet, "A", "D", 26)
+		_ = f.AutoFilter(sheet, fmt.Sprintf("A1:D%d", row-1), nil)
+
 	case []models.SessionPhone:
 		sheet := writeSheet("Sessions")
 		_ = f.SetSheetRow(sheet, "A1", &[]interface{}{"session_id", "phone", "ai_active", "created_at", "last_message_at", "lead_name"})
@@ -659,6 +680,8 @@ func writeAsPDF(w http.ResponseWriter, data interface{}, filename string) {
 			{"Abandonment Rate", fmt.Sprintf("%.2f%%", v.AbandonmentRate)},
 			{"Total Engaged Sessions", fmt.Sprintf("%d", v.TotalEngagedSessions)},
 			{"Engaged Abandonment Rate", fmt.Sprintf("%.2f%%", v.EngagedAbandonmentRate)},
+			{"Total Contacts", fmt.Sprintf("%d", v.TotalContacts)},
+			{"Completed Contacts", fmt.Sprintf("%d", v.CompletedContacts)},
 		})
 
 	case FlowDepthResponse:
@@ -718,6 +741,29 @@ func writeAsPDF(w http.ResponseWriter, data interface{}, filename string) {
 			}
 		}
 
+	case PipelineReport:
+		addHeader("Report: Pipeline")
+		writeKeyValBlock([][2]string{
+			{"Interval", v.Interval},
+			{"From", v.From.UTC().Format(time.RFC3339)},
+			{"To", v.To.UTC().Format(time.RFC3339)},
+		})
+		pdf.Ln(4)
+		headers := []string{"Period", "Stage", "Count", "Value"}
+		colWidths := []float64{50, 50, 40, 46}
+		var rows [][]string
+		for _, p := range v.Periods {
+			for _, st := range p.Stages {
+				rows = append(rows, []string{
+					p.Start.UTC().Format("2006-01-02"),
+					st.Stage,
+					fmt.Sprintf("%d", st.Count),
+					fmt.Sprintf("%.2f", st.Value),
+				})
+			}
+		}
+		writeSimpleTable(headers, rows, colWidths)
+
 	case []models.SessionPhone:
 		addHeader("Report: Sessions")
 
@@ -0,0 +1,91 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/models"
+)
+
+// ScheduledMessagesHandler handles /scheduledmessages
+// GET ?session_id= lists pending scheduled messages (all=true includes sent
+// and cancelled ones); DELETE ?id= cancels one that has not gone out yet.
+func ScheduledMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		listScheduled(w, r)
+	case http.MethodDelete:
+		cancelScheduled(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func listScheduled(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sid := q.Get("session_id")
+	if sid == "" {
+		http.Error(w, "session_id is required", http.StatusBadRequest)
+		return
+	}
+
+	items := []models.OutboundMessage{}
+	tx := db.DB.Where("session_id = ? AND send_at IS NOT NULL", sid)
+	if q.Get("all") != "true" {
+		tx = tx.Where("status = ?", models.OutboundPending)
+	}
+	tx.Order("send_at asc").Find(&items)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+func cancelScheduled(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	// Only rows the worker has not claimed yet can be cancelled.
+	result := db.DB.Model(&models.OutboundMessage{}).
+		Where("id = ? AND send_at IS NOT NULL AND status = ?", id, models.OutboundPending).
+		Update("status", models.OutboundCancelled)
+	if result.RowsAffected == 0 {
+		http.Error(w, "scheduled message not found or already sent", http.StatusNotFound)
+		return
+	}
+
+	var m models.OutboundMessage
+	db.DB.First(&m, id)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
+
+// pendingScheduledHistory renders the session's scheduled messages as
+// history items flagged pending, so the chat view can show them in place.
+func pendingScheduledHistory(sessionID string) []HistoryResponse {
+	var items []models.OutboundMessage
+	db.DB.Where("session_id = ? AND send_at IS NOT NULL AND status IN ?", sessionID,
+		[]string{models.OutboundPending, models.OutboundSending}).
+		Order("send_at asc").
+		Find(&items)
+
+	out := make([]HistoryResponse, 0, len(items))
+	for _, m := range items {
+		msg := aiMessage{Message: m.Body, Attachment: outboundMedia(&m), Sender: outboundSender(&m)}
+		_ = json.Unmarshal(m.Vars, &msg.Vars)
+		id := m.ID
+		out = append(out, HistoryResponse{
+			SessionID:   m.SessionID,
+			CreatedAt:   *m.SendAt,
+			Message:     parseMessage(buildAIMessage(msg)),
+			Sender:      msg.Sender,
+			Pending:     true,
+			ScheduledID: &id,
+		})
+	}
+	return out
+}
@@ -0,0 +1,251 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"html"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	searchIndexInterval  = 30 * time.Second
+	searchIndexBatch     = 500
+	searchIndexLookback  = 1000
+	defaultSearchLimit   = 20
+	maxSearchLimit       = 100
+	searchHighlightStart = "\x02"
+	searchHighlightStop  = "\x03"
+)
+
+// SearchHit is one matching message. Snippet is HTML-escaped with the
+// matched words wrapped in <mark>.
+type SearchHit struct {
+	HistoryID uint      `json:"history_id"`
+	SessionID string    `json:"session_id"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	Snippet   string    `json:"snippet"`
+	Rank      float64   `json:"rank"`
+	Phone     string    `json:"phone"`
+	LeadName  string    `json:"lead_name"`
+	AIActive  bool      `json:"ai_active"`
+}
+
+type SearchResponse struct {
+	Query  string      `json:"query"`
+	Total  int64       `json:"total"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+	Hits   []SearchHit `json:"hits"`
+}
+
+// searchableText returns the role and readable text of a chat history
+// message, decoding the nested content like parseMessage: the lead's text,
+// or output.message plus the string vars (especialidade, nome_do_lead...)
+// for bot and panel messages.
+func searchableText(raw string) (role, text string) {
+	top, ok := parseMessage(raw).(map[string]interface{})
+	if !ok {
+		return "", raw
+	}
+	role, _ = top["type"].(string)
+
+	switch c := top["content"].(type) {
+	case string:
+		return role, c
+	case map[string]interface{}:
+		output, _ := c["output"].(map[string]interface{})
+		if output == nil {
+			return role, ""
+		}
+		parts := []string{}
+		if m, ok := output["message"].(string); ok && m != "" {
+			parts = append(parts, m)
+		}
+		if vars, ok := output["vars"].(map[string]interface{}); ok {
+			keys := make([]string, 0, len(vars))
+			for k := range vars {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if s, ok := vars[k].(string); ok && s != "" {
+					parts = append(parts, s)
+				}
+			}
+		}
+		return role, strings.Join(parts, "\n")
+	}
+	return role, ""
+}
+
+// StartSearchIndexer keeps chat_search_index in step with
+// n8n_chat_histories until ctx is cancelled. The first runs backfill the
+// existing history in batches.
+func StartSearchIndexer(ctx context.Context) {
+	ticker := time.NewTicker(searchIndexInterval)
+	defer ticker.Stop()
+
+	for {
+		for indexChatHistory() == searchIndexBatch {
+			if ctx.Err() != nil {
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// indexChatHistory indexes up to searchIndexBatch rows that have no entry
+// yet and returns how many it wrote. Rows a little below the newest indexed
+// id are rechecked, since ids may commit out of order.
+func indexChatHistory() int {
+	var maxID uint
+	db.DB.Model(&models.ChatSearchEntry{}).Select("COALESCE(MAX(history_id), 0)").Scan(&maxID)
+	floor := 0
+	if maxID > searchIndexLookback {
+		floor = int(maxID) - searchIndexLookback
+	}
+
+	var rows []models.ChatHistory
+	if err := db.DB.
+		Where("id > ?", floor).
+		Where("NOT EXISTS (SELECT 1 FROM chat_search_index i WHERE i.history_id = n8n_chat_histories.id)").
+		Order("id").
+		Limit(searchIndexBatch).
+		Find(&rows).Error; err != nil {
+		log.Printf("❌ Failed to load chat history for search: %v", err)
+		return 0
+	}
+	if len(rows) == 0 {
+		return 0
+	}
+
+	entries := make([]models.ChatSearchEntry, 0, len(rows))
+	for _, h := range rows {
+		role, text := searchableText(h.Message)
+		entries = append(entries, models.ChatSearchEntry{
+			HistoryID: h.ID,
+			SessionID: h.SessionID,
+			Role:      role,
+			Body:      text,
+			CreatedAt: h.CreatedAt,
+		})
+	}
+	if err := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&entries).Error; err != nil {
+		log.Printf("❌ Failed to index chat history: %v", err)
+		return 0
+	}
+	return len(rows)
+}
+
+// SearchHandler handles GET /search
+// ?q= is a web-style query (quoted phrases, -exclusions, "or"). Optional
+// filters: from/to (RFC3339, on the message time), tag/tags and ai_active.
+// Paginated with limit/offset, best matches first.
+func SearchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	text := strings.TrimSpace(q.Get("q"))
+	if text == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultSearchLimit
+	if n, err := strconv.Atoi(q.Get("limit")); err == nil && n > 0 {
+		limit = n
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	offset := 0
+	if n, err := strconv.Atoi(q.Get("offset")); err == nil && n > 0 {
+		offset = n
+	}
+
+	tx := db.DB.Table("chat_search_index i").
+		Joins("JOIN session_phones sp ON sp.session_id = i.session_id").
+		Where("i.tsv @@ websearch_to_tsquery('portuguese', ?)", text)
+	for _, p := range []struct{ param, cond string }{
+		{"from", "i.created_at >= ?"},
+		{"to", "i.created_at <= ?"},
+	} {
+		v := q.Get(p.param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid "+p.param+"; use RFC3339", http.StatusBadRequest)
+			return
+		}
+		tx = tx.Where(p.cond, t.UTC())
+	}
+	if v := q.Get("ai_active"); v != "" {
+		active, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "invalid ai_active", http.StatusBadRequest)
+			return
+		}
+		tx = tx.Where("sp.ai_active = ?", active)
+	}
+	if tags := tagsFromQuery(r); len(tags) > 0 {
+		tx = tx.Where("i.session_id IN (?)", whereSessionTags(db.DB.Table("session_phones").Select("session_id"), tags))
+	}
+
+	tx = tx.Session(&gorm.Session{})
+
+	resp := SearchResponse{Query: text, Limit: limit, Offset: offset, Hits: []SearchHit{}}
+	if err := tx.Count(&resp.Total).Error; err != nil {
+		http.Error(w, "search failed", http.StatusInternalServerError)
+		return
+	}
+
+	headline := "StartSel=" + searchHighlightStart + ", StopSel=" + searchHighlightStop +
+		", MaxFragments=2, MaxWords=20, MinWords=6, FragmentDelimiter= … "
+	if err := tx.
+		Select(`i.history_id, i.session_id, i.role, i.created_at,
+			ts_headline('portuguese', i.body, websearch_to_tsquery('portuguese', ?), ?) AS snippet,
+			ts_rank(i.tsv, websearch_to_tsquery('portuguese', ?)) AS rank,
+			sp.phone, sp.lead_name, sp.ai_active`, text, headline, text).
+		Order("rank DESC, i.created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Scan(&resp.Hits).Error; err != nil {
+		http.Error(w, "search failed", http.StatusInternalServerError)
+		return
+	}
+	for i := range resp.Hits {
+		resp.Hits[i].Snippet = highlightSnippet(resp.Hits[i].Snippet)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// highlightSnippet escapes the message text and turns the ts_headline
+// markers into <mark> tags, so the panel can render it as HTML safely.
+func highlightSnippet(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, searchHighlightStart, "<mark>")
+	return strings.ReplaceAll(s, searchHighlightStop, "</mark>")
+}
@@ -1,25 +1,49 @@
 package routes
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
-	"net/url"
-	"os"
 	"strings"
 	"time"
 
 	"bestdoctors_service/internal/db"
+	"bestdoctors_service/internal/messaging"
+	"bestdoctors_service/internal/phonenum"
+	"bestdoctors_service/internal/session"
+	"bestdoctors_service/middleware"
 	"bestdoctors_service/models"
 )
 
+var messageProvider messaging.MessageProvider
+
+// InitMessageProvider selects the WhatsApp provider from the environment.
+func InitMessageProvider() error {
+	p, err := messaging.NewProviderFromEnv()
+	if err != nil {
+		return err
+	}
+	messageProvider = p
+	return nil
+}
+
+// SetMessageProvider overrides the provider, e.g. with a messaging.FakeProvider.
+func SetMessageProvider(p messaging.MessageProvider) {
+	messageProvider = p
+}
+
 type SendMessageRequest struct {
 	To        string                 `json:"to"`
 	Message   string                 `json:"message"`
 	SessionID string                 `json:"session_id"`
 	Vars      map[string]interface{} `json:"vars"`
+	// Template, when set, replaces Message with the rendered template body.
+	Template string            `json:"template"`
+	Language string            `json:"language"`
+	Params   map[string]string `json:"params"`
+	// SendAt schedules the message instead of sending it right away.
+	SendAt *time.Time `json:"send_at"`
 }
 
 func SendMessageHandler(w http.ResponseWriter, r *http.Request) {
@@ -28,96 +52,245 @@ func SendMessageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req SendMessageRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	// --- Idempotency-Key já usada: devolve a mensagem gravada, sem efeitos colaterais ---
+	key := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if key != "" {
+		existing, err := findOutboundByKey(key)
+		if err != nil {
+			log.Printf("❌ Failed to look up idempotency key: %v", err)
+			http.Error(w, "failed to enqueue message", http.StatusInternalServerError)
+			return
+		}
+		if existing != nil {
+			writeSendResponse(w, existing, storedHistory(existing), "")
+			return
+		}
+	}
+
+	var (
+		req    SendMessageRequest
+		upload *uploadedFile
+	)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		var err error
+		req, upload, err = parseMultipartSend(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid JSON body", http.StatusBadRequest)
 		return
 	}
+	if req.To == "" || req.SessionID == "" || (req.Message == "" && req.Template == "" && upload == nil) {
+		http.Error(w, "to, session_id and message, template or file are required", http.StatusBadRequest)
+		return
+	}
+	if upload != nil && req.Template != "" {
+		http.Error(w, "attachments cannot be combined with templates", http.StatusBadRequest)
+		return
+	}
+	to, err := phonenum.Normalize(req.To)
+	if err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.To = to
+	if optedOut, err := isOptedOut(req.To); err != nil {
+		log.Printf("❌ Failed to check consent for %s: %v", req.To, err)
+		http.Error(w, "failed to check consent", http.StatusInternalServerError)
+		return
+	} else if optedOut {
+		http.Error(w, errOptedOut.Error(), http.StatusForbidden)
+		return
+	}
 
-	// Helper para limpar CRLF (Windows line endings)
-	cleanEnv := func(key string) string {
-		v := os.Getenv(key)
-		v = strings.TrimSpace(v)
-		v = strings.ReplaceAll(v, "\r", "")
-		v = strings.ReplaceAll(v, "\n", "")
-		return v
+	varsJSON, _ := json.Marshal(req.Vars)
+	out := models.OutboundMessage{
+		SessionID: req.SessionID,
+		Recipient: req.To,
+		Body:      req.Message,
+		Vars:      varsJSON,
+	}
+	if sd, ok := r.Context().Value(middleware.SessionDataKey).(*session.SessionData); ok && sd.UserID > 0 {
+		out.SenderUserID = &sd.UserID
+		out.SenderUsername = sd.Username
 	}
 
-	// --- pega as vars da .env ---
-	accountSID := cleanEnv("TWILIO_ACCOUNT_SID")
-	authToken := cleanEnv("TWILIO_AUTH_TOKEN")
-	apiURL := cleanEnv("TWILIO_URL")
-	fromNumber := "whatsapp:+554134111916"
+	// --- agendamento: o worker do outbox envia quando chegar a hora ---
+	sendAt := time.Now().UTC()
+	if req.SendAt != nil && req.SendAt.After(sendAt) {
+		sendAt = req.SendAt.UTC()
+		out.SendAt = &sendAt
+		out.NextAttemptAt = sendAt
+	}
 
-	// Validação básica
-	if accountSID == "" || authToken == "" || apiURL == "" {
-		log.Println("❌ Twilio credentials missing in .env")
-		http.Error(w, "Twilio configuration missing", http.StatusInternalServerError)
+	// --- templates: horário comercial e limite de mensagens proativas por telefone ---
+	deferredReason := ""
+	if at, reason := checkSendPolicy(req.To, req.Template != "", sendAt); at.After(sendAt) {
+		sendAt = at
+		out.SendAt = &sendAt
+		out.NextAttemptAt = sendAt
+		deferredReason = reason
+	}
+
+	// --- template (HSM) ou texto livre dentro da janela de 24h ---
+	if req.Template != "" {
+		tpl, err := findTemplate(req.Template, req.Language)
+		if err != nil {
+			if isNotFound(err) {
+				http.Error(w, "template not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "failed to load template", http.StatusInternalServerError)
+			return
+		}
+		body, _, err := providerTemplate(tpl, req.Params)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		paramsJSON, _ := json.Marshal(req.Params)
+		out.Body = body
+		out.TemplateID = &tpl.ID
+		out.TemplateParams = paramsJSON
+	} else if err := checkSessionWindow(req.SessionID, sendAt); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
 		return
 	}
 
-	// --- envia mensagem pelo Twilio ---
-	form := url.Values{}
-	form.Set("To", req.To)
-	form.Set("From", fromNumber)
-	form.Set("Body", req.Message)
+	// --- anexo: sobe para o storage e o provedor baixa pela URL ---
+	if upload != nil {
+		media, err := storeUpload(r.Context(), upload)
+		if err != nil {
+			log.Printf("❌ Failed to store attachment: %v", err)
+			http.Error(w, "failed to store attachment", http.StatusInternalServerError)
+			return
+		}
+		out.Media, _ = json.Marshal(media)
+	}
 
-	httpReq, err := http.NewRequest("POST", apiURL, strings.NewReader(form.Encode()))
-	if err != nil {
-		log.Printf("❌ Failed to create Twilio request: %v", err)
-		http.Error(w, "Failed to create request", http.StatusInternalServerError)
-		return
+	// --- grava no outbox (idempotente via header Idempotency-Key) ---
+	if key != "" {
+		out.IdempotencyKey = &key
 	}
-	
-	httpReq.SetBasicAuth(accountSID, authToken)
-	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := http.DefaultClient.Do(httpReq)
+	duplicate, err := enqueueOutbound(&out)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("twilio request failed: %v", err), http.StatusInternalServerError)
+		log.Printf("❌ Failed to enqueue message: %v", err)
+		http.Error(w, "failed to enqueue message", http.StatusInternalServerError)
 		return
 	}
-	defer resp.Body.Close()
 
-	body, _ := ioutil.ReadAll(resp.Body)
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		http.Error(w, fmt.Sprintf("twilio error %d: %s", resp.StatusCode, string(body)), http.StatusBadGateway)
+	// --- primeira tentativa imediata; falhas ficam para o worker ---
+	var history *models.ChatHistory
+	if !duplicate && out.SendAt == nil && claimOutbound(out.ID) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		history, _ = dispatchOutbound(ctx, &out)
+		cancel()
+	} else {
+		history = storedHistory(&out)
+	}
+	if duplicate {
+		deferredReason = ""
+	}
+
+	writeSendResponse(w, &out, history, deferredReason)
+}
+
+// storedHistory loads the ChatHistory row of an already sent outbox row.
+func storedHistory(out *models.OutboundMessage) *models.ChatHistory {
+	if out.ChatHistoryID == nil {
+		return nil
+	}
+	var h models.ChatHistory
+	if err := db.DB.First(&h, *out.ChatHistoryID).Error; err != nil {
+		return nil
+	}
+	return &h
+}
+
+// writeSendResponse answers SendMessageHandler with the state of out.
+func writeSendResponse(w http.ResponseWriter, out *models.OutboundMessage, history *models.ChatHistory, deferredReason string) {
+	if out.Status == models.OutboundDead {
+		http.Error(w, out.LastError, http.StatusBadGateway)
 		return
 	}
+	status := out.Status
+	if out.SendAt != nil && status == models.OutboundPending {
+		status = "scheduled"
+	}
+	resp := map[string]interface{}{
+		"status":  status,
+		"outbox":  out,
+		"history": history,
+	}
+	if deferredReason != "" {
+		resp["status"] = "deferred"
+		resp["deferred_reason"] = deferredReason
+		resp["deferred_until"] = out.SendAt
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if out.Status != models.OutboundSent {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// MessageSender identifies the panel user behind a manual message.
+type MessageSender struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// outboundSender returns the sender recorded on an outbox row, if any.
+func outboundSender(m *models.OutboundMessage) *MessageSender {
+	if m.SenderUserID == nil {
+		return nil
+	}
+	return &MessageSender{UserID: *m.SenderUserID, Username: m.SenderUsername}
+}
+
+// aiMessage is a panel-sent message before it is stored as ChatHistory.
+type aiMessage struct {
+	Message    string
+	Vars       map[string]interface{}
+	Kwargs     map[string]interface{}
+	Attachment *messaging.Media
+	Sender     *MessageSender
+}
 
+// buildAIMessage renders a panel-sent message in the same shape the n8n
+// agent stores its own "ai" turns. Attachments go next to the text in
+// output.attachment.
+func buildAIMessage(m aiMessage) string {
 	// --- monta o JSON do content como STRING ---
-	output := map[string]interface{}{
-		"output": map[string]interface{}{
-			"message": req.Message,
-			"vars":    req.Vars,
-		},
+	body := map[string]interface{}{
+		"message": m.Message,
+		"vars":    m.Vars,
+	}
+	if m.Attachment != nil {
+		body["attachment"] = m.Attachment
+	}
+	outputJSON, _ := json.MarshalIndent(map[string]interface{}{"output": body}, "", "  ")
+
+	kwargs := m.Kwargs
+	if kwargs == nil {
+		kwargs = map[string]interface{}{}
 	}
-	outputJSON, _ := json.MarshalIndent(output, "", "  ")
 
-	// Objeto final a ser salvo no banco
 	msg := map[string]interface{}{
 		"type":               "ai",
 		"content":            string(outputJSON), // vira string JSON escapada
 		"tool_calls":         []interface{}{},
-		"additional_kwargs":  map[string]interface{}{},
+		"additional_kwargs":  kwargs,
 		"response_metadata":  map[string]interface{}{},
 		"invalid_tool_calls": []interface{}{},
 	}
-	jsonMsg, _ := json.Marshal(msg)
-
-	// --- salva no banco ---
-	history := models.ChatHistory{
-		SessionID: req.SessionID,
-		Message:   string(jsonMsg),
-		CreatedAt: time.Now().UTC(),
+	// n8n ignores unknown keys; "sender" marks attendant turns.
+	if m.Sender != nil {
+		msg["sender"] = m.Sender
 	}
-	db.DB.Create(&history)
-
-	// --- responde ao cliente ---
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":  "sent",
-		"twilio":  json.RawMessage(body),
-		"history": history,
-	})
+	jsonMsg, _ := json.Marshal(msg)
+	return string(jsonMsg)
 }
@@ -0,0 +1,158 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/internal/phonenum"
+	"bestdoctors_service/models"
+)
+
+// Reasons a send is pushed back by the send policy.
+const (
+	deferQuietHours     = "quiet_hours"
+	deferProactiveLimit = "proactive_limit"
+)
+
+var errDeferred = errors.New("send deferred by send policy")
+
+func defaultSendPolicy() models.SendPolicy {
+	return models.SendPolicy{
+		ID:                   1,
+		Timezone:             "America/Sao_Paulo",
+		BusinessStart:        "08:00",
+		BusinessEnd:          "20:00",
+		BusinessDays:         "1,2,3,4,5,6",
+		MaxProactive:         3,
+		ProactiveWindowHours: 24,
+	}
+}
+
+// loadSendPolicy reads the clinic's policy, falling back to the defaults
+// when the row is missing so sends never fail because of it.
+func loadSendPolicy() models.SendPolicy {
+	var p models.SendPolicy
+	if err := db.DB.First(&p, 1).Error; err != nil {
+		return defaultSendPolicy()
+	}
+	return p
+}
+
+// parseClock parses "HH:MM" into minutes since midnight.
+func parseClock(s string) (int, bool) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 2 {
+		return 0, false
+	}
+	h, err1 := strconv.Atoi(parts[0])
+	m, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || h < 0 || h > 24 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, false
+	}
+	return h*60 + m, true
+}
+
+// parseBusinessDays parses a comma-separated list of weekday numbers
+// (0 = Sunday).
+func parseBusinessDays(s string) (map[time.Weekday]bool, bool) {
+	days := map[time.Weekday]bool{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		d, err := strconv.Atoi(part)
+		if err != nil || d < 0 || d > 6 {
+			return nil, false
+		}
+		days[time.Weekday(d)] = true
+	}
+	return days, len(days) > 0
+}
+
+// nextBusinessTime returns t when it falls inside business hours, otherwise
+// the start of the next business period. A policy that cannot be parsed
+// does not block anything.
+func nextBusinessTime(p models.SendPolicy, t time.Time) time.Time {
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	start, ok1 := parseClock(p.BusinessStart)
+	end, ok2 := parseClock(p.BusinessEnd)
+	days, ok3 := parseBusinessDays(p.BusinessDays)
+	if !ok1 || !ok2 || !ok3 || start >= end {
+		return t
+	}
+
+	local := t.In(loc)
+	for i := 0; i < 8; i++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+i, 0, 0, 0, 0, loc)
+		if !days[day.Weekday()] {
+			continue
+		}
+		open := day.Add(time.Duration(start) * time.Minute)
+		closeAt := day.Add(time.Duration(end) * time.Minute)
+		if i == 0 {
+			if local.Before(open) {
+				return open.UTC()
+			}
+			if local.Before(closeAt) {
+				return t
+			}
+			continue
+		}
+		return open.UTC()
+	}
+	return t
+}
+
+// proactiveLimitResetAt returns when recipient may get another proactive
+// (template) message, or the zero time if the limit is not reached.
+func proactiveLimitResetAt(p models.SendPolicy, recipient string, now time.Time) time.Time {
+	if p.MaxProactive <= 0 || p.ProactiveWindowHours <= 0 {
+		return time.Time{}
+	}
+	window := time.Duration(p.ProactiveWindowHours) * time.Hour
+
+	var sentAt []time.Time
+	if err := db.DB.Model(&models.OutboundMessage{}).
+		Where("status = ? AND template_id IS NOT NULL AND sent_at > ?", models.OutboundSent, now.Add(-window)).
+		Where("regexp_replace(recipient, '\\D', '', 'g') = ?", phonenum.Key(recipient)).
+		Order("sent_at asc").
+		Pluck("sent_at", &sentAt).Error; err != nil {
+		log.Printf("❌ Failed to count proactive messages: %v", err)
+		return time.Time{}
+	}
+	if len(sentAt) < p.MaxProactive {
+		return time.Time{}
+	}
+	return sentAt[len(sentAt)-p.MaxProactive].Add(window)
+}
+
+// checkSendPolicy returns the earliest time a message to recipient may go
+// out starting from at, and why it was pushed back ("" when it wasn't).
+// Only proactive messages are held back: template sends, the ones that open
+// a conversation. Free-form replies answer a patient inside the 24h window
+// and go out whenever the attendant sends them.
+func checkSendPolicy(recipient string, proactive bool, at time.Time) (time.Time, string) {
+	if !proactive {
+		return at, ""
+	}
+	p := loadSendPolicy()
+	reason := ""
+	if reset := proactiveLimitResetAt(p, recipient, at); reset.After(at) {
+		at, reason = reset, deferProactiveLimit
+	}
+	if next := nextBusinessTime(p, at); next.After(at) {
+		at = next
+		if reason == "" {
+			reason = deferQuietHours
+		}
+	}
+	return at, reason
+}
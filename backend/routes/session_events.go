@@ -0,0 +1,117 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/internal/session"
+	"bestdoctors_service/models"
+
+	"gorm.io/gorm"
+)
+
+var errVersionConflict = errors.New("session was changed by someone else; reload and try again")
+
+// recordSessionEvent appends ev to the session's audit log, filling in who
+// made the change.
+func recordSessionEvent(tx *gorm.DB, ev models.SessionEvent, actor *session.SessionData) error {
+	if actor != nil {
+		if actor.UserID > 0 {
+			id := actor.UserID
+			ev.UserID = &id
+		}
+		ev.Username = actor.Username
+	}
+	if ev.CreatedAt.IsZero() {
+		ev.CreatedAt = time.Now().UTC()
+	}
+	return tx.Create(&ev).Error
+}
+
+// setAIActive turns the AI on or off for a session and logs the change.
+// Setting the current value again is a no-op (changed is false), whatever
+// the expected version, so a retried request doesn't fail. Otherwise, when
+// expected is set, the update only applies if the session is still at that
+// version; if not, errVersionConflict is returned.
+func setAIActive(tx *gorm.DB, sid string, active bool, expected *int, actor *session.SessionData, reason string) (s models.SessionPhone, changed bool, err error) {
+	if err := tx.First(&s, "session_id = ?", sid).Error; err != nil {
+		return s, false, err
+	}
+	if s.AIActive == active {
+		return s, false, nil
+	}
+	if expected != nil && *expected != s.Version {
+		return s, false, errVersionConflict
+	}
+
+	res := tx.Model(&models.SessionPhone{}).
+		Where("session_id = ? AND version = ?", sid, s.Version).
+		Updates(map[string]interface{}{
+			"ai_active": active,
+			"version":   gorm.Expr("version + 1"),
+		})
+	if res.Error != nil {
+		return s, false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return s, false, errVersionConflict
+	}
+
+	if err := recordSessionEvent(tx, models.SessionEvent{
+		SessionID: sid,
+		EventType: models.SessionEventAIActive,
+		OldValue:  strconv.FormatBool(s.AIActive),
+		NewValue:  strconv.FormatBool(active),
+		Reason:    reason,
+	}, actor); err != nil {
+		return s, false, err
+	}
+
+	s.AIActive = active
+	s.Version++
+	return s, true, nil
+}
+
+// SessionEventsHandler handles GET /sessionevents?session_id=
+func SessionEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	sid := r.URL.Query().Get("session_id")
+	if sid == "" {
+		http.Error(w, "session_id is required", http.StatusBadRequest)
+		return
+	}
+
+	events := []models.SessionEvent{}
+	db.DB.Where("session_id = ?", sid).Order("created_at asc").Find(&events)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// sessionEventHistory renders the audit log as history items so the chat
+// view can show "AI turned off by ana" between messages.
+func sessionEventHistory(sid string, since *time.Time) []HistoryResponse {
+	var events []models.SessionEvent
+	tx := db.DB.Where("session_id = ?", sid)
+	if since != nil {
+		tx = tx.Where("created_at > ?", *since)
+	}
+	tx.Order("created_at asc").Find(&events)
+
+	out := make([]HistoryResponse, 0, len(events))
+	for i := range events {
+		out = append(out, HistoryResponse{
+			SessionID: sid,
+			CreatedAt: events[i].CreatedAt,
+			Event:     &events[i],
+		})
+	}
+	return out
+}
@@ -0,0 +1,116 @@
+package routes
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/models"
+
+	"gorm.io/gorm"
+)
+
+// SessionListItem is a session as listed in the panel, with the lead
+// messages the current user has not read yet.
+type SessionListItem struct {
+	models.SessionPhone
+	UnreadCount        int64      `json:"unread_count"`
+	LastHumanMessageAt *time.Time `json:"last_human_message_at,omitempty"`
+}
+
+type SessionReadRequest struct {
+	SessionID string `json:"session_id"`
+	// HistoryID is the newest message seen; the latest message when omitted.
+	HistoryID uint `json:"history_id,omitempty"`
+}
+
+// withUnread selects the session_phones rows of tx together with the
+// unread count for userID and the time of the last lead message. Sessions
+// the user never opened count all their lead messages. Recapture markers
+// are stored as human rows but are written by campaigns, not the lead.
+func withUnread(tx *gorm.DB, userID int) *gorm.DB {
+	return tx.Select(`session_phones.*,
+		(SELECT COUNT(*) FROM n8n_chat_histories h
+		  WHERE h.session_id = session_phones.session_id
+		    AND (h.message::jsonb ->> 'type') = 'human'
+		    AND COALESCE(h.message::jsonb ->> 'content', '') NOT LIKE ?
+		    AND h.created_at > COALESCE((SELECT r.last_read_at FROM session_reads r
+		        WHERE r.session_id = session_phones.session_id AND r.user_id = ?), '-infinity')) AS unread_count,
+		(SELECT MAX(h.created_at) FROM n8n_chat_histories h
+		  WHERE h.session_id = session_phones.session_id
+		    AND (h.message::jsonb ->> 'type') = 'human'
+		    AND COALESCE(h.message::jsonb ->> 'content', '') NOT LIKE ?) AS last_human_message_at`,
+		recapturePrefix+"%", userID, recapturePrefix+"%")
+}
+
+func sessionUserID(r *http.Request) int {
+	if sd := sessionUser(r); sd != nil {
+		return sd.UserID
+	}
+	return 0
+}
+
+// markSessionRead moves userID's read marker for sessionID up to the given
+// chat message, or the latest one when historyID is 0. It never moves back,
+// so loading an older page of history leaves it alone. The message's own
+// created_at is stored, which keeps the comparison on the database clock.
+func markSessionRead(tx *gorm.DB, userID int, sessionID string, historyID uint) error {
+	if userID <= 0 || sessionID == "" {
+		return nil
+	}
+	pick := "ORDER BY created_at DESC, id DESC LIMIT 1"
+	args := []interface{}{userID, sessionID}
+	if historyID > 0 {
+		pick = "AND id = ?"
+		args = append(args, historyID)
+	}
+	return tx.Exec(`INSERT INTO session_reads (user_id, session_id, last_read_at, updated_at)
+		SELECT ?, session_id, created_at, NOW() FROM n8n_chat_histories
+		WHERE session_id = ? `+pick+`
+		ON CONFLICT (user_id, session_id) DO UPDATE
+		SET last_read_at = GREATEST(session_reads.last_read_at, EXCLUDED.last_read_at),
+		    updated_at = NOW()`, args...).Error
+}
+
+// SessionReadHandler handles POST /sessionphone/read
+// Acknowledges the session's messages for the current user (up to
+// history_id, or all of them) and returns the session with its counters.
+func SessionReadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req SessionReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SessionID == "" {
+		http.Error(w, `body must be {"session_id": "..."}`, http.StatusBadRequest)
+		return
+	}
+	userID := sessionUserID(r)
+	if userID <= 0 {
+		http.Error(w, "read markers need a panel user", http.StatusForbidden)
+		return
+	}
+
+	var s models.SessionPhone
+	if err := db.DB.Select("session_id").First(&s, "session_id = ?", req.SessionID).Error; err != nil {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	if err := markSessionRead(db.DB, userID, req.SessionID, req.HistoryID); err != nil {
+		log.Printf("❌ Failed to mark %s as read: %v", req.SessionID, err)
+		http.Error(w, "failed to mark session as read", http.StatusInternalServerError)
+		return
+	}
+	var item SessionListItem
+	if err := withUnread(db.DB.Model(&models.SessionPhone{}), userID).
+		Where("session_id = ?", req.SessionID).
+		Take(&item).Error; err != nil {
+		http.Error(w, "failed to load session", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
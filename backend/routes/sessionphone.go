@@ -1,9 +1,14 @@
 package routes
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"bestdoctors_service/internal/db"
@@ -12,7 +17,112 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	defaultSessionPageSize = 50
+	maxSessionPageSize     = 500
+)
+
+// SessionPage is the /sessionphone envelope used with limit or cursor.
+// NextCursor is empty on the last page.
+type SessionPage struct {
+	Sessions   []SessionListItem `json:"sessions"`
+	Total      int64             `json:"total"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// sessionCursor is the position after the last session of a page. It also
+// pins the sort, so following pages keep the order they started with.
+type sessionCursor struct {
+	Sort  string    `json:"s"`
+	Desc  bool      `json:"d,omitempty"`
+	At    time.Time `json:"t,omitempty"`
+	Index string    `json:"i"`
+}
+
+func (c sessionCursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSessionCursor(v string) (sessionCursor, error) {
+	var c sessionCursor
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err == nil {
+		err = json.Unmarshal(b, &c)
+	}
+	if err != nil || (c.Sort != "session_id" && c.Sort != "last_message_at") {
+		return c, errors.New("invalid cursor")
+	}
+	return c, nil
+}
+
+// sessionListFilters applies the /sessionphone filters: session_id,
+// phone_prefix (digits only are compared), lead_name (substring),
+// ai_active, last_message_from/last_message_to (RFC3339), flow_state
+// (repeatable) and tag/tags.
+func sessionListFilters(tx *gorm.DB, r *http.Request) (*gorm.DB, error) {
+	q := r.URL.Query()
+	if sid := q.Get("session_id"); sid != "" {
+		tx = tx.Where("session_id = ?", sid)
+	}
+	if v := q.Get("phone_prefix"); v != "" {
+		digits := digitsOnly(v)
+		if digits == "" {
+			return nil, errors.New("phone_prefix must contain digits")
+		}
+		tx = tx.Where("REGEXP_REPLACE(phone, '[^0-9]', '', 'g') LIKE ?", digits+"%")
+	}
+	if v := strings.TrimSpace(q.Get("lead_name")); v != "" {
+		tx = tx.Where("lead_name ILIKE ?", "%"+escapeLike(v)+"%")
+	}
+	if v := q.Get("ai_active"); v != "" {
+		active, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.New("invalid ai_active")
+		}
+		tx = tx.Where("ai_active = ?", active)
+	}
+	for _, p := range []struct{ param, cond string }{
+		{"last_message_from", "last_message_at >= ?"},
+		{"last_message_to", "last_message_at <= ?"},
+	} {
+		if v := q.Get(p.param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s; use RFC3339", p.param)
+			}
+			tx = tx.Where(p.cond, t.UTC())
+		}
+	}
+	if vals := q["flow_state"]; len(vals) > 0 {
+		states := make([]int, 0, len(vals))
+		for _, v := range vals {
+			for _, part := range strings.Split(v, ",") {
+				n, err := strconv.Atoi(strings.TrimSpace(part))
+				if err != nil || n < 0 || n > 5 {
+					return nil, errors.New("flow_state must be between 0 and 5")
+				}
+				states = append(states, n)
+			}
+		}
+		tx = tx.Where("flow_state IN ?", states)
+	}
+	return whereSessionTags(tx, tagsFromQuery(r)), nil
+}
+
+// escapeLike escapes LIKE wildcards in user input.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 // SessionPhoneHandler handles GET /sessionphone
+// Filters: see sessionListFilters. sort=session_id (default) or
+// last_message_at, with order=asc|desc (last_message_at defaults to desc).
+// With limit or cursor the response is a SessionPage and pages are keyset
+// based, so sessions arriving meanwhile don't shift them. Without either,
+// the plain array is returned, optionally cut with the 1-based inclusive
+// from/to. Every session carries the current user's unread_count and
+// last_human_message_at.
 func SessionPhoneHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -21,26 +131,108 @@ func SessionPhoneHandler(w http.ResponseWriter, r *http.Request) {
 
 	q := r.URL.Query()
 
-	sessions := []models.SessionPhone{}
-	tx := db.DB
-	if sid := q.Get("session_id"); sid != "" {
-		tx = tx.Where("session_id = ?", sid)
+	tx, err := sessionListFilters(db.DB.Model(&models.SessionPhone{}), r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tx = tx.Session(&gorm.Session{})
+
+	cur := sessionCursor{Sort: "session_id"}
+	if v := q.Get("cursor"); v != "" {
+		if cur, err = decodeSessionCursor(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		switch q.Get("sort") {
+		case "", "session_id":
+		case "last_message_at":
+			cur.Sort = "last_message_at"
+			cur.Desc = true
+		default:
+			http.Error(w, "sort must be session_id or last_message_at", http.StatusBadRequest)
+			return
+		}
+		switch q.Get("order") {
+		case "":
+		case "asc":
+			cur.Desc = false
+		case "desc":
+			cur.Desc = true
+		default:
+			http.Error(w, "order must be asc or desc", http.StatusBadRequest)
+			return
+		}
+	}
+	dir := "asc"
+	if cur.Desc {
+		dir = "desc"
+	}
+	listed := withUnread(tx, sessionUserID(r))
+	ordered := listed.Order("session_id " + dir)
+	if cur.Sort == "last_message_at" {
+		ordered = listed.Order("last_message_at " + dir).Order("session_id " + dir)
 	}
 
-	// Optional pagination: from/to (1-based, inclusive)
+	w.Header().Set("Content-Type", "application/json")
+	if q.Get("limit") == "" && q.Get("cursor") == "" {
+		writeSessionRange(w, ordered, q.Get("from"), q.Get("to"))
+		return
+	}
+
+	limit := defaultSessionPageSize
+	if n, err := strconv.Atoi(q.Get("limit")); err == nil && n > 0 {
+		limit = n
+	}
+	if limit > maxSessionPageSize {
+		limit = maxSessionPageSize
+	}
+
+	page := SessionPage{Sessions: []SessionListItem{}}
+	if err := tx.Count(&page.Total).Error; err != nil {
+		http.Error(w, "failed to count sessions", http.StatusInternalServerError)
+		return
+	}
+
+	if cur.Index != "" {
+		cmp := ">"
+		if cur.Desc {
+			cmp = "<"
+		}
+		if cur.Sort == "last_message_at" {
+			ordered = ordered.Where("(last_message_at, session_id) "+cmp+" (?, ?)", cur.At, cur.Index)
+		} else {
+			ordered = ordered.Where("session_id "+cmp+" ?", cur.Index)
+		}
+	}
+	// One extra row tells whether there is a next page.
+	if err := ordered.Limit(limit + 1).Scan(&page.Sessions).Error; err != nil {
+		http.Error(w, "failed to load sessions", http.StatusInternalServerError)
+		return
+	}
+	if len(page.Sessions) > limit {
+		page.Sessions = page.Sessions[:limit]
+		last := page.Sessions[limit-1]
+		cur.Index = last.SessionID
+		cur.At = last.LastMessageAt
+		page.NextCursor = cur.encode()
+	}
+	_ = json.NewEncoder(w).Encode(page)
+}
+
+// writeSessionRange writes the plain array form, cut by the legacy 1-based
+// inclusive from/to.
+func writeSessionRange(w http.ResponseWriter, tx *gorm.DB, fromParam, toParam string) {
 	var (
 		fromVal int
 		toVal   int
 	)
-	if v := q.Get("from"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil && n > 0 {
-			fromVal = n
-		}
+	if n, err := strconv.Atoi(fromParam); err == nil && n > 0 {
+		fromVal = n
 	}
-	if v := q.Get("to"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil && n > 0 {
-			toVal = n
-		}
+	if n, err := strconv.Atoi(toParam); err == nil && n > 0 {
+		toVal = n
 	}
 	if fromVal > 0 || toVal > 0 {
 		if fromVal <= 0 {
@@ -55,36 +247,88 @@ func SessionPhoneHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	tx.Order("session_id").Find(&sessions)
-
-	w.Header().Set("Content-Type", "application/json")
+	sessions := []SessionListItem{}
+	tx.Scan(&sessions)
 	_ = json.NewEncoder(w).Encode(sessions)
 }
 
 
+// ToggleAIRequest is the body of PATCH /sessionphone/active. Version is the
+// session version the client last saw; an If-Match header works as well.
+type ToggleAIRequest struct {
+    AIActive *bool  `json:"ai_active"`
+    Version  *int   `json:"version,omitempty"`
+    Reason   string `json:"reason,omitempty"`
+}
+
 // ToggleAIHandler handles PATCH /sessionphone/active
+// Sets ai_active to the value in the body, so retries are harmless. A stale
+// version gets 412 with the current session in the body.
 func ToggleAIHandler(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodPatch {
         w.WriteHeader(http.StatusMethodNotAllowed)
         return
     }
     sid := r.URL.Query().Get("session_id")
-    result := db.DB.Model(&models.SessionPhone{}).
-        Where("session_id = ?", sid).
-        Update("ai_active", gorm.Expr("NOT ai_active"))
-    if result.RowsAffected == 0 {
-        http.Error(w, "session not found", http.StatusNotFound)
+
+    var req ToggleAIRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.AIActive == nil {
+        http.Error(w, `body must be {"ai_active": true|false}`, http.StatusBadRequest)
         return
     }
+    if v := strings.Trim(r.Header.Get("If-Match"), `W/" `); v != "" && req.Version == nil {
+        n, err := strconv.Atoi(v)
+        if err != nil {
+            http.Error(w, "invalid If-Match version", http.StatusBadRequest)
+            return
+        }
+        req.Version = &n
+    }
+
+    actor := sessionUser(r)
     var s models.SessionPhone
-    db.DB.First(&s, "session_id = ?", sid)
+    err := db.DB.Transaction(func(tx *gorm.DB) error {
+        // AI on: whoever held the session lets go. Released here, before the
+        // update, so the handoff trigger (AI off queues the session, AI on
+        // releases it) finds nothing left and released_by is kept.
+        if *req.AIActive {
+            var by *int
+            if actor != nil {
+                by = &actor.UserID
+            }
+            if err := releaseHandoff(tx, sid, by, time.Now().UTC()); err != nil {
+                return err
+            }
+        }
+        var err error
+        s, _, err = setAIActive(tx, sid, *req.AIActive, req.Version, actor, req.Reason)
+        return err
+    })
 
     w.Header().Set("Content-Type", "application/json")
+    switch {
+    case errors.Is(err, gorm.ErrRecordNotFound):
+        http.Error(w, "session not found", http.StatusNotFound)
+        return
+    case errors.Is(err, errVersionConflict):
+        db.DB.First(&s, "session_id = ?", sid)
+        w.Header().Set("ETag", fmt.Sprintf(`"%d"`, s.Version))
+        w.WriteHeader(http.StatusPreconditionFailed)
+        json.NewEncoder(w).Encode(s)
+        return
+    case err != nil:
+        log.Printf("❌ Failed to set ai_active for %s: %v", sid, err)
+        http.Error(w, "failed to update session", http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("ETag", fmt.Sprintf(`"%d"`, s.Version))
     json.NewEncoder(w).Encode(s)
 }
 
 // SessionDeltaHandler handles GET /sessiondelta
-// Returns only sessions with new messages since the given timestamp.
+// Returns only sessions with new messages since the given timestamp, with
+// the current user's unread counters.
 func SessionDeltaHandler(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodGet {
         w.WriteHeader(http.StatusMethodNotAllowed)
@@ -105,11 +349,11 @@ func SessionDeltaHandler(w http.ResponseWriter, r *http.Request) {
         since = time.Unix(0, 0)
     }
 
-    var sessions []models.SessionPhone
-    db.DB.
+    sessions := []SessionListItem{}
+    withUnread(whereSessionTags(db.DB.Model(&models.SessionPhone{}), tagsFromQuery(r)), sessionUserID(r)).
         Where("last_message_at > ?", since).
         Order("last_message_at asc").
-        Find(&sessions)
+        Scan(&sessions)
 
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(sessions)
@@ -0,0 +1,358 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var tagColorRegex = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+type TagRequest struct {
+	Name  *string `json:"name"`
+	Color *string `json:"color"`
+}
+
+// SessionTagRequest tags a session by tag ID or by name; unknown names are
+// created on the fly.
+type SessionTagRequest struct {
+	SessionID string `json:"session_id"`
+	TagID     uint   `json:"tag_id,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+}
+
+// tagsFromQuery reads ?tag=a&tag=b (or ?tags=a,b).
+func tagsFromQuery(r *http.Request) []string {
+	q := r.URL.Query()
+	tags := q["tag"]
+	if v := q.Get("tags"); v != "" {
+		tags = append(tags, strings.Split(v, ",")...)
+	}
+	return normalizeTagNames(tags)
+}
+
+// tagsFromFilters reads the "tags" report filter (array or comma list).
+func tagsFromFilters(filters map[string]interface{}) []string {
+	var tags []string
+	switch v := filters["tags"].(type) {
+	case string:
+		tags = strings.Split(v, ",")
+	case []interface{}:
+		for _, t := range v {
+			if s, ok := t.(string); ok {
+				tags = append(tags, s)
+			}
+		}
+	}
+	return normalizeTagNames(tags)
+}
+
+func normalizeTagNames(tags []string) []string {
+	out := make([]string, 0, len(tags))
+	seen := map[string]bool{}
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// whereSessionTags keeps sessions carrying any of tags (lower-cased names).
+// q must select from a table with a session_id column.
+func whereSessionTags(q *gorm.DB, tags []string) *gorm.DB {
+	if len(tags) == 0 {
+		return q
+	}
+	return q.Where("session_id IN (?)", db.DB.Table("session_tags st").
+		Select("st.session_id").
+		Joins("JOIN tags t ON t.id = st.tag_id").
+		Where("LOWER(t.name) IN ?", tags))
+}
+
+// TagsHandler handles /tags
+// GET lists tags with how many sessions carry each; POST creates one.
+func TagsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		listTags(w)
+	case http.MethodPost:
+		createTag(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// TagHandler handles /tags/{id}: PUT renames/recolours, DELETE removes the
+// tag from every session.
+func TagHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/bestdoctors/tags/"), "/"))
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid tag id", http.StatusBadRequest)
+		return
+	}
+	var t models.Tag
+	if err := db.DB.First(&t, id).Error; err != nil {
+		http.Error(w, "tag not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		updateTag(w, r, &t)
+	case http.MethodDelete:
+		if err := db.DB.Delete(&t).Error; err != nil {
+			http.Error(w, "failed to delete tag", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+type tagWithCount struct {
+	models.Tag
+	Sessions int64 `json:"sessions"`
+}
+
+func listTags(w http.ResponseWriter) {
+	tags := []tagWithCount{}
+	db.DB.Table("tags t").
+		Select("t.*, COUNT(st.session_id) AS sessions").
+		Joins("LEFT JOIN session_tags st ON st.tag_id = t.id").
+		Group("t.id").
+		Order("t.name").
+		Scan(&tags)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+func validateTag(name string, color *string) error {
+	if name == "" || utf8.RuneCountInString(name) > 50 {
+		return errors.New("name must have 1 to 50 characters")
+	}
+	if color != nil && *color != "" && !tagColorRegex.MatchString(*color) {
+		return errors.New("color must look like #1a2b3c")
+	}
+	return nil
+}
+
+func createTag(w http.ResponseWriter, r *http.Request) {
+	var req TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == nil {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(*req.Name)
+	if err := validateTag(name, req.Color); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := findTagByName(name); err == nil {
+		http.Error(w, "tag already exists", http.StatusConflict)
+		return
+	}
+
+	t := models.Tag{Name: name}
+	if req.Color != nil {
+		t.Color = *req.Color
+	}
+	if sd := sessionUser(r); sd != nil && sd.UserID > 0 {
+		t.CreatedBy = &sd.UserID
+	}
+	if err := db.DB.Create(&t).Error; err != nil {
+		http.Error(w, "failed to create tag", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(t)
+}
+
+func updateTag(w http.ResponseWriter, r *http.Request, t *models.Tag) {
+	var req TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	name := t.Name
+	if req.Name != nil {
+		name = strings.TrimSpace(*req.Name)
+	}
+	if err := validateTag(name, req.Color); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if other, err := findTagByName(name); err == nil && other.ID != t.ID {
+		http.Error(w, "tag already exists", http.StatusConflict)
+		return
+	}
+
+	t.Name = name
+	if req.Color != nil {
+		t.Color = *req.Color
+	}
+	if err := db.DB.Save(t).Error; err != nil {
+		http.Error(w, "failed to update tag", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+func findTagByName(name string) (*models.Tag, error) {
+	var t models.Tag
+	if err := db.DB.Where("LOWER(name) = LOWER(?)", strings.TrimSpace(name)).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// SessionTagsHandler handles /sessiontags
+// GET ?session_id= lists the session's tags; POST adds one; DELETE
+// ?session_id=&tag_id= removes one. Changes go to the session event log.
+func SessionTagsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		sid := r.URL.Query().Get("session_id")
+		if sid == "" {
+			http.Error(w, "session_id is required", http.StatusBadRequest)
+			return
+		}
+		writeSessionTags(w, sid)
+	case http.MethodPost:
+		addSessionTag(w, r)
+	case http.MethodDelete:
+		removeSessionTag(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func sessionTags(sid string) []models.Tag {
+	tags := []models.Tag{}
+	db.DB.Table("tags t").
+		Select("t.*").
+		Joins("JOIN session_tags st ON st.tag_id = t.id").
+		Where("st.session_id = ?", sid).
+		Order("t.name").
+		Scan(&tags)
+	return tags
+}
+
+func writeSessionTags(w http.ResponseWriter, sid string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessionTags(sid))
+}
+
+func addSessionTag(w http.ResponseWriter, r *http.Request) {
+	var req SessionTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SessionID == "" || (req.TagID == 0 && req.Tag == "") {
+		http.Error(w, "session_id and tag_id or tag are required", http.StatusBadRequest)
+		return
+	}
+	var s models.SessionPhone
+	if err := db.DB.First(&s, "session_id = ?", req.SessionID).Error; err != nil {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	actor := sessionUser(r)
+	var t models.Tag
+	switch {
+	case req.TagID != 0:
+		if err := db.DB.First(&t, req.TagID).Error; err != nil {
+			http.Error(w, "tag not found", http.StatusNotFound)
+			return
+		}
+	default:
+		name := strings.TrimSpace(req.Tag)
+		if existing, err := findTagByName(name); err == nil {
+			t = *existing
+		} else {
+			if err := validateTag(name, nil); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			t = models.Tag{Name: name}
+			if actor != nil && actor.UserID > 0 {
+				t.CreatedBy = &actor.UserID
+			}
+			if err := db.DB.Create(&t).Error; err != nil {
+				http.Error(w, "failed to create tag", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		st := models.SessionTag{SessionID: s.SessionID, TagID: t.ID}
+		if actor != nil && actor.UserID > 0 {
+			st.CreatedBy = &actor.UserID
+		}
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&st)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		return recordSessionEvent(tx, models.SessionEvent{
+			SessionID: s.SessionID,
+			EventType: models.SessionEventTagAdded,
+			NewValue:  t.Name,
+		}, actor)
+	})
+	if err != nil {
+		http.Error(w, "failed to tag session", http.StatusInternalServerError)
+		return
+	}
+	writeSessionTags(w, s.SessionID)
+}
+
+func removeSessionTag(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sid := q.Get("session_id")
+	tagID, err := strconv.Atoi(q.Get("tag_id"))
+	if sid == "" || err != nil || tagID <= 0 {
+		http.Error(w, "session_id and tag_id are required", http.StatusBadRequest)
+		return
+	}
+	var t models.Tag
+	if err := db.DB.First(&t, tagID).Error; err != nil {
+		http.Error(w, "tag not found", http.StatusNotFound)
+		return
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("session_id = ? AND tag_id = ?", sid, tagID).Delete(&models.SessionTag{})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		return recordSessionEvent(tx, models.SessionEvent{
+			SessionID: sid,
+			EventType: models.SessionEventTagRemoved,
+			OldValue:  t.Name,
+		}, sessionUser(r))
+	})
+	if err != nil {
+		http.Error(w, "failed to untag session", http.StatusInternalServerError)
+		return
+	}
+	writeSessionTags(w, sid)
+}
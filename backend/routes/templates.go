@@ -0,0 +1,92 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/internal/messaging"
+	"bestdoctors_service/models"
+
+	"gorm.io/gorm"
+)
+
+// sessionWindow is how long after the contact's last message WhatsApp
+// accepts free-form messages.
+const sessionWindow = 24 * time.Hour
+
+const defaultTemplateLanguage = "pt_BR"
+
+var errOutsideWindow = errors.New("outside the 24-hour session window: use an approved template")
+
+// TemplatesHandler handles GET /templates
+// Lists the active templates attendants may send.
+func TemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var templates []models.MessageTemplate
+	db.DB.Where("is_active = true").Order("name, language").Find(&templates)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(templates)
+}
+
+// findTemplate loads an active template by name and language.
+func findTemplate(name, language string) (*models.MessageTemplate, error) {
+	if language == "" {
+		language = defaultTemplateLanguage
+	}
+	var t models.MessageTemplate
+	if err := db.DB.Where("name = ? AND language = ? AND is_active = true", name, language).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// providerTemplate builds the provider payload of a stored template.
+func providerTemplate(t *models.MessageTemplate, params map[string]string) (string, *messaging.Template, error) {
+	body, ordered, err := messaging.RenderTemplate(t.Body, params)
+	if err != nil {
+		return "", nil, err
+	}
+	return body, &messaging.Template{
+		ContentID: t.ProviderContentSID,
+		Language:  t.Language,
+		Params:    ordered,
+	}, nil
+}
+
+// lastHumanMessageAt returns when the contact last wrote in the session.
+// Recapture markers are stored as human rows by campaign sends, so they
+// don't count: they must not reopen the window.
+func lastHumanMessageAt(sessionID string) (time.Time, bool) {
+	var h models.ChatHistory
+	err := db.DB.
+		Where("session_id = ? AND message::jsonb ->> 'type' = 'human'", sessionID).
+		Where("COALESCE(message::jsonb ->> 'content', '') NOT LIKE ?", recapturePrefix+"%").
+		Order("created_at desc").
+		First(&h).Error
+	if err != nil {
+		return time.Time{}, false
+	}
+	return h.CreatedAt, true
+}
+
+// checkSessionWindow refuses free-form messages that would go out after the
+// 24-hour customer service window closes.
+func checkSessionWindow(sessionID string, at time.Time) error {
+	last, ok := lastHumanMessageAt(sessionID)
+	if !ok || at.Sub(last) > sessionWindow {
+		return errOutsideWindow
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, gorm.ErrRecordNotFound)
+}
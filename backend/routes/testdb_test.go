@@ -0,0 +1,116 @@
+package routes
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"bestdoctors_service/internal/db"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// n8nTables are the tables n8n owns; the migrations only alter them.
+const n8nTables = `
+CREATE TABLE n8n_chat_histories (
+    id SERIAL PRIMARY KEY,
+    session_id VARCHAR(255) NOT NULL,
+    message JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE TABLE session_phones (
+    session_id VARCHAR(255) PRIMARY KEY,
+    phone VARCHAR(50),
+    ai_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_message_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    lead_name VARCHAR(255)
+);`
+
+// openTestDB points db.DB at a fresh schema of the database in
+// TEST_DATABASE_URL, with the n8n tables and every migration applied, and
+// drops the schema when the test ends. Without the variable the test is
+// skipped.
+func openTestDB(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	cfg := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+
+	admin, err := gorm.Open(postgres.Open(dsn), cfg)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+
+	// Simple protocol so each migration file runs as one multi-statement Exec.
+	conn, err := gorm.Open(postgres.New(postgres.Config{
+		DSN:                  withSearchPath(dsn, schema),
+		PreferSimpleProtocol: true,
+	}), cfg)
+	if err != nil {
+		t.Fatalf("connect to %s: %v", schema, err)
+	}
+
+	prevDB, prevSupabase := db.DB, db.SupabaseDB
+	t.Cleanup(func() {
+		db.DB, db.SupabaseDB = prevDB, prevSupabase
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := conn.Exec(n8nTables).Error; err != nil {
+		t.Fatalf("create n8n tables: %v", err)
+	}
+	files, err := filepath.Glob("../migrations/*.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no migrations found: %v", err)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		body, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// A multi-statement Exec runs in one implicit transaction, where
+		// CONCURRENTLY is not allowed; the tables are empty anyway.
+		sql := strings.ReplaceAll(string(body), "CONCURRENTLY ", "")
+		if err := conn.Exec(sql).Error; err != nil {
+			t.Fatalf("%s: %v", filepath.Base(f), err)
+		}
+	}
+
+	db.DB, db.SupabaseDB = conn, conn
+}
+
+// withSearchPath adds search_path to a URL or key=value connection string.
+func withSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return dsn
+		}
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return dsn + " search_path=" + schema
+}
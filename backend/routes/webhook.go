@@ -0,0 +1,240 @@
+package routes
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"bestdoctors_service/internal/config"
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/internal/messaging"
+	"bestdoctors_service/internal/phonenum"
+	"bestdoctors_service/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WhatsAppWebhookHandler handles /webhooks/whatsapp
+// Receives provider callbacks directly, so replies are stored even when the
+// n8n workflow is down. Twilio (X-Twilio-Signature) and Meta
+// (X-Hub-Signature-256) payloads are accepted; anything unsigned is rejected.
+func WhatsAppWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		metaVerifyChallenge(w, r)
+	case http.MethodPost:
+		if r.Header.Get("X-Twilio-Signature") != "" {
+			twilioInbound(w, r)
+			return
+		}
+		if r.Header.Get("X-Hub-Signature-256") != "" {
+			metaInbound(w, r)
+			return
+		}
+		http.Error(w, "missing webhook signature", http.StatusUnauthorized)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// metaVerifyChallenge answers the subscription handshake of the Cloud API.
+func metaVerifyChallenge(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	token := config.Env("META_VERIFY_TOKEN")
+	if q.Get("hub.mode") != "subscribe" || token == "" || q.Get("hub.verify_token") != token {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte(q.Get("hub.challenge")))
+}
+
+func twilioInbound(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form body", http.StatusBadRequest)
+		return
+	}
+	if !messaging.VerifyTwilioSignature(config.Env("TWILIO_AUTH_TOKEN"), webhookURL(r), r.PostForm, r.Header.Get("X-Twilio-Signature")) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	if in, ok := messaging.ParseTwilioInbound(r.PostForm); ok {
+		if err := storeInbound(in); err != nil {
+			log.Printf("❌ Failed to store inbound message %s: %v", in.ProviderMessageID, err)
+			http.Error(w, "failed to store message", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	_, _ = w.Write([]byte("<Response></Response>"))
+}
+
+func metaInbound(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	if !messaging.VerifyMetaSignature(config.Env("META_APP_SECRET"), body, r.Header.Get("X-Hub-Signature-256")) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	msgs, err := messaging.ParseMetaInbound(body)
+	if err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	for _, in := range msgs {
+		if err := storeInbound(in); err != nil {
+			log.Printf("❌ Failed to store inbound message %s: %v", in.ProviderMessageID, err)
+			http.Error(w, "failed to store message", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	statuses, _ := messaging.ParseMetaStatuses(body)
+	for _, u := range statuses {
+		if err := applyStatusUpdate(u); err != nil {
+			log.Printf("❌ Failed to update delivery %s: %v", u.ProviderMessageID, err)
+			http.Error(w, "failed to update delivery", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// webhookURL rebuilds the public URL the provider signed. WEBHOOK_BASE_URL
+// wins; otherwise the proxy headers are trusted.
+func webhookURL(r *http.Request) string {
+	if base := config.Env("WEBHOOK_BASE_URL"); base != "" {
+		return strings.TrimRight(base, "/") + r.URL.RequestURI()
+	}
+	scheme := r.Header.Get("X-Forwarded-Proto")
+	if scheme == "" {
+		scheme = "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+	}
+	host := r.Header.Get("X-Forwarded-Host")
+	if host == "" {
+		host = r.Host
+	}
+	u := url.URL{Scheme: scheme, Host: host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+	return u.String()
+}
+
+// storeInbound writes the message as a "human" ChatHistory row, the same
+// shape n8n uses, and bumps the session's last_message_at. Provider retries
+// are ignored: the provider message ID is claimed in inbound_messages
+// first, and a concurrent retry waits on that row and then finds it taken.
+func storeInbound(in messaging.InboundMessage) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		inbound := models.InboundMessage{
+			ProviderMessageID: in.ProviderMessageID,
+			Provider:          in.Provider,
+			ReceivedAt:        in.ReceivedAt,
+		}
+		if in.ProviderMessageID != "" {
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&inbound)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return nil
+			}
+		}
+
+		var s models.SessionPhone
+		err := tx.Where("phone IN ?", phonenum.Variants(in.From)).
+			Order("last_message_at desc").
+			First(&s).Error
+		switch {
+		case err == gorm.ErrRecordNotFound:
+			e164, err := phonenum.Normalize(in.From)
+			if err != nil {
+				log.Printf("⚠️ Storing unnormalized sender %q: %v", in.From, err)
+				e164 = strings.TrimPrefix(in.From, "whatsapp:")
+			}
+			s = models.SessionPhone{
+				SessionID:     strings.TrimPrefix(e164, "+"),
+				Phone:         e164,
+				AIActive:      true,
+				CreatedAt:     in.ReceivedAt,
+				LastMessageAt: in.ReceivedAt,
+				LeadName:      in.ProfileName,
+			}
+			if err := tx.Create(&s).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			if err := tx.Model(&s).Update("last_message_at", in.ReceivedAt).Error; err != nil {
+				return err
+			}
+		}
+		if err := linkSessionContact(tx, &s); err != nil {
+			return err
+		}
+
+		kwargs := map[string]interface{}{
+			"provider":            in.Provider,
+			"provider_message_id": in.ProviderMessageID,
+		}
+		if in.Media != nil {
+			kwargs["attachment"] = in.Media
+		}
+		msg := map[string]interface{}{
+			"type":              "human",
+			"content":           in.Body,
+			"additional_kwargs": kwargs,
+			"response_metadata": map[string]interface{}{},
+		}
+		jsonMsg, _ := json.Marshal(msg)
+
+		history := models.ChatHistory{
+			SessionID: s.SessionID,
+			Message:   string(jsonMsg),
+			CreatedAt: in.ReceivedAt,
+		}
+		if err := tx.Create(&history).Error; err != nil {
+			return err
+		}
+		if in.ProviderMessageID != "" {
+			if err := tx.Model(&inbound).Updates(map[string]interface{}{
+				"session_id":      s.SessionID,
+				"chat_history_id": history.ID,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		// "PARAR"/"STOP" and friends update consent in the same transaction;
+		// StartConsentKeywordScanner covers the messages n8n writes.
+		if kw := messaging.DetectConsentKeyword(in.Body); kw != "" {
+			event := models.ConsentEventOptIn
+			if kw == messaging.ConsentOptOut {
+				event = models.ConsentEventOptOut
+			}
+			return setConsent(tx, models.ConsentEvent{
+				Phone:     in.From,
+				Event:     event,
+				Source:    models.ConsentSourceInbound,
+				SessionID: s.SessionID,
+				Message:   in.Body,
+				HistoryID: &history.ID,
+				CreatedAt: in.ReceivedAt,
+			})
+		}
+		return nil
+	})
+}
@@ -0,0 +1,151 @@
+package routes
+
+import (
+	"testing"
+	"time"
+
+	"bestdoctors_service/internal/db"
+	"bestdoctors_service/internal/messaging"
+	"bestdoctors_service/models"
+)
+
+func inbound(id, body string) messaging.InboundMessage {
+	return messaging.InboundMessage{
+		Provider:          "twilio",
+		ProviderMessageID: id,
+		From:              "whatsapp:" + testPhone,
+		ProfileName:       "Ana",
+		Body:              body,
+		ReceivedAt:        time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+func countRows(t *testing.T, model interface{}, query string, args ...interface{}) int64 {
+	t.Helper()
+	var n int64
+	if err := db.DB.Model(model).Where(query, args...).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// Providers redeliver webhooks; the same message is only stored once.
+func TestStoreInboundDedupe(t *testing.T) {
+	openTestDB(t)
+	in := inbound("SM1", "Oi")
+
+	for i := 0; i < 2; i++ {
+		if err := storeInbound(in); err != nil {
+			t.Fatalf("delivery %d: %v", i+1, err)
+		}
+	}
+
+	if n := countRows(t, &models.ChatHistory{}, "session_id = ?", "5541999998888"); n != 1 {
+		t.Errorf("stored %d history rows, want 1", n)
+	}
+	var claimed models.InboundMessage
+	if err := db.DB.First(&claimed, "provider_message_id = ?", "SM1").Error; err != nil {
+		t.Fatal(err)
+	}
+	if claimed.SessionID != "5541999998888" || claimed.ChatHistoryID == nil {
+		t.Errorf("inbound = %+v, want it linked to the stored message", claimed)
+	}
+}
+
+func TestStoreInboundUnknownSender(t *testing.T) {
+	openTestDB(t)
+	in := inbound("SM1", "Oi")
+
+	if err := storeInbound(in); err != nil {
+		t.Fatal(err)
+	}
+
+	var s models.SessionPhone
+	if err := db.DB.First(&s, "session_id = ?", "5541999998888").Error; err != nil {
+		t.Fatalf("session not created: %v", err)
+	}
+	if s.Phone != testPhone || s.LeadName != "Ana" || !s.AIActive || !s.LastMessageAt.Equal(in.ReceivedAt) {
+		t.Errorf("session = %+v", s)
+	}
+	if s.ContactID == nil {
+		t.Error("session not linked to a contact")
+	}
+	last, ok := lastHumanMessageAt(s.SessionID)
+	if !ok || !last.Equal(in.ReceivedAt) {
+		t.Errorf("last human message at %s (%v), want %s", last, ok, in.ReceivedAt)
+	}
+}
+
+// A sender matching an existing session under another form of the number
+// (here without the 9th digit) writes to that session.
+func TestStoreInboundKnownSender(t *testing.T) {
+	openTestDB(t)
+	old := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	if err := db.DB.Create(&models.SessionPhone{
+		SessionID:     "legacy",
+		Phone:         "554199998888",
+		AIActive:      true,
+		CreatedAt:     old,
+		LastMessageAt: old,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	in := inbound("SM1", "Oi")
+
+	if err := storeInbound(in); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := countRows(t, &models.SessionPhone{}, "1 = 1"); n != 1 {
+		t.Errorf("%d sessions, want the existing one only", n)
+	}
+	var s models.SessionPhone
+	db.DB.First(&s, "session_id = ?", "legacy")
+	if !s.LastMessageAt.Equal(in.ReceivedAt) {
+		t.Errorf("last_message_at = %s, want %s", s.LastMessageAt, in.ReceivedAt)
+	}
+	if n := countRows(t, &models.ChatHistory{}, "session_id = ?", "legacy"); n != 1 {
+		t.Errorf("stored %d history rows in the session, want 1", n)
+	}
+}
+
+func TestStoreInboundConsentKeywords(t *testing.T) {
+	openTestDB(t)
+
+	if err := storeInbound(inbound("SM1", " parar ")); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := isOptedOut(testPhone); err != nil || !out {
+		t.Fatalf("after PARAR: opted out = %v, %v; want true", out, err)
+	}
+	var ev models.ConsentEvent
+	if err := db.DB.First(&ev, "event = ?", models.ConsentEventOptOut).Error; err != nil {
+		t.Fatalf("no opt-out event: %v", err)
+	}
+	if ev.Source != models.ConsentSourceInbound || ev.SessionID != "5541999998888" || ev.HistoryID == nil {
+		t.Errorf("event = %+v", ev)
+	}
+
+	// A redelivery neither stores nor applies the keyword again.
+	if err := storeInbound(inbound("SM1", " parar ")); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRows(t, &models.ConsentEvent{}, "1 = 1"); n != 1 {
+		t.Errorf("%d consent events after a redelivery, want 1", n)
+	}
+
+	if err := storeInbound(inbound("SM2", "VOLTAR")); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := isOptedOut(testPhone); err != nil || out {
+		t.Errorf("after VOLTAR: opted out = %v, %v; want false", out, err)
+	}
+
+	// Other messages leave consent alone.
+	if err := storeInbound(inbound("SM3", "quero parar de receber promoções")); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRows(t, &models.ConsentEvent{}, "1 = 1"); n != 2 {
+		t.Errorf("%d consent events, want 2", n)
+	}
+}
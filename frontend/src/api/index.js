@@ -46,10 +46,15 @@ export async function fetchHistory(sessionId, signal) {
   );
 }
 
-export async function toggleSessionActive(sessionId, signal) {
+export async function toggleSessionActive(sessionId, aiActive, version, signal) {
   return safeFetch(
     `${API_BASE}/bestdoctors/sessionphone/active?session_id=${encodeURIComponent(sessionId)}`,
-    { method: 'PATCH', signal }
+    {
+      method: 'PATCH',
+      headers: { 'Content-Type': 'application/json' },
+      signal,
+      body: JSON.stringify({ ai_active: !!aiActive, version })
+    }
   );
 }
 
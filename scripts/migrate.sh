@@ -0,0 +1,30 @@
+#!/bin/sh
+# Applies backend/migrations/*.sql in order, once each. Applied files are
+# recorded in schema_migrations, so migrations with one-off backfills are
+# not re-run on every deploy.
+
+set -e
+
+MIGRATIONS_DIR="${MIGRATIONS_DIR:-/migrations}"
+export PGPASSWORD="$PG_PASSWORD"
+
+psql_db() {
+    psql -h "$PG_HOST" -p "$PG_PORT" -U "$PG_USER" -d "$PG_DATABASE" -v ON_ERROR_STOP=1 "$@"
+}
+
+psql_db -qc "CREATE TABLE IF NOT EXISTS schema_migrations (
+    filename VARCHAR(255) PRIMARY KEY,
+    applied_at TIMESTAMP DEFAULT NOW()
+)"
+
+for file in $(ls "$MIGRATIONS_DIR"/*.sql | sort); do
+    name=$(basename "$file")
+    if [ "$(psql_db -tAc "SELECT 1 FROM schema_migrations WHERE filename = '$name'")" = "1" ]; then
+        continue
+    fi
+    echo "▶ $name"
+    psql_db -q -f "$file"
+    psql_db -qc "INSERT INTO schema_migrations (filename) VALUES ('$name')"
+done
+
+echo "✅ Migrations up to date"